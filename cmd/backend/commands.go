@@ -0,0 +1,251 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"go-blog/pkg/api"
+	"go-blog/pkg/util/archive"
+	"go-blog/pkg/util/config"
+	"go-blog/pkg/util/importer"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rwbm/go-tools/files"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// starts the HTTP server, and the watcher unless disabled
+func serveCommand(cfg *config.Configuration, args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	withWatcher := fs.Bool("watch", true, "start the templates watcher too")
+	fs.Parse(args)
+
+	return api.Serve(cfg, *withWatcher)
+}
+
+// processes a single template
+func ingestCommand(cfg *config.Configuration, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: backend ingest <file>")
+	}
+
+	return api.Ingest(cfg, args[0])
+}
+
+// starts the watcher only
+func watchCommand(cfg *config.Configuration, args []string) error {
+	return api.Watch(cfg)
+}
+
+// applies, reverts or lists database migrations
+func migrateCommand(cfg *config.Configuration, args []string) (err error) {
+	action := "up"
+	if len(args) > 0 {
+		action, args = args[0], args[1:]
+	}
+
+	switch action {
+	case "up":
+		count, errUp := api.MigrateUp(cfg)
+		fmt.Fprintf(os.Stderr, "%d migrations applied\n", count)
+		return errUp
+
+	case "down":
+		fs := flag.NewFlagSet("migrate down", flag.ExitOnError)
+		steps := fs.Int("steps", 1, "number of migrations to revert")
+		fs.Parse(args)
+
+		count, errDown := api.MigrateDown(cfg, *steps)
+		fmt.Fprintf(os.Stderr, "%d migrations reverted\n", count)
+		return errDown
+
+	case "status":
+		status, errStatus := api.MigrationStatus(cfg)
+		if errStatus != nil {
+			return errStatus
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range status {
+			appliedAt := "pending"
+			if s.Applied {
+				appliedAt = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		return w.Flush()
+	}
+
+	return errors.New("usage: backend migrate up|down [-steps n]|status")
+}
+
+// exports all posts to stdout or to a file, as JSON lines or as a zip archive
+func exportCommand(cfg *config.Configuration, args []string) (err error) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	output := fs.String("output", "", "file to write the posts to; stdout is used if not set")
+	format := fs.String("format", "", "write a zip archive in this format: "+strings.Join(archive.Formats, ", "))
+	fs.Parse(args)
+
+	if *format != "" && !archive.IsFormat(*format) {
+		return fmt.Errorf("unsupported export format '%s'; valid formats are %s", *format, strings.Join(archive.Formats, ", "))
+	}
+
+	w := os.Stdout
+	if *output != "" {
+		if w, err = os.Create(*output); err != nil {
+			return
+		}
+		defer w.Close()
+	}
+
+	count := 0
+	if *format == "" {
+		count, err = api.Export(cfg, w)
+	} else {
+		count, err = api.ExportArchive(cfg, w, *format)
+	}
+	if err != nil {
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "%d posts exported\n", count)
+	return
+}
+
+// imports posts from a file written by export, or from other blog engines
+func importCommand(cfg *config.Configuration, args []string) (err error) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	from := fs.String("from", "", "source to import posts from: "+strings.Join(importer.Sources(), ", "))
+	dryRun := fs.Bool("dry-run", false, "report the posts that would be imported, without saving them")
+	author := fs.String("author", "", "author of posts with no author in the source")
+	fs.Parse(args)
+
+	args = fs.Args()
+	if len(args) != 1 {
+		return errors.New("usage: backend import [-from source] [-dry-run] [-author name] <path>")
+	}
+
+	if *from != "" {
+		return importFromCommand(cfg, *from, args[0], *author, *dryRun)
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	count, err := api.Import(cfg, f)
+	if cfg.Server.DryRun {
+		fmt.Fprintf(os.Stderr, "dry run; %d posts would be imported\n", count)
+	} else {
+		fmt.Fprintf(os.Stderr, "%d posts imported\n", count)
+	}
+
+	return
+}
+
+// imports posts from other blog engines, printing the outcome of each one of them
+func importFromCommand(cfg *config.Configuration, source, path, author string, dryRun bool) error {
+
+	report, err := api.ImportFrom(cfg, source, path, author, dryRun)
+	dryRun = report.DryRun
+	if report.Results == nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STATUS\tSLUG\tSOURCE\tMESSAGE")
+	for _, r := range report.Results {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Status, r.Slug, r.Source, r.Message)
+	}
+	w.Flush()
+
+	if dryRun {
+		fmt.Fprint(os.Stderr, "dry run; nothing was saved. ")
+	}
+	fmt.Fprintf(os.Stderr, "%d imported, %d duplicates, %d skipped, %d invalid, %d failed\n",
+		report.Counts[importer.StatusImported],
+		report.Counts[importer.StatusDuplicate],
+		report.Counts[importer.StatusSkipped],
+		report.Counts[importer.StatusInvalid],
+		report.Counts[importer.StatusFailed])
+
+	return err
+}
+
+// cleans up categories and tags
+func reindexCommand(cfg *config.Configuration, args []string) (err error) {
+	removed, err := api.Reindex(cfg)
+	if err != nil {
+		return
+	}
+
+	if cfg.Server.DryRun {
+		fmt.Fprintf(os.Stderr, "dry run; reindex would remove %d rows\n", removed)
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "reindex completed; %d rows removed\n", removed)
+	return
+}
+
+// writes a snapshot of the database
+func backupCommand(cfg *config.Configuration, args []string) (err error) {
+	if len(args) != 1 {
+		return errors.New("usage: backend backup <file>")
+	}
+
+	if err = api.Backup(cfg, args[0]); err != nil {
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "backup written to %s\n", args[0])
+	return
+}
+
+// replaces the database with a backup
+func restoreCommand(cfg *config.Configuration, args []string) (err error) {
+	if len(args) != 1 {
+		return errors.New("usage: backend restore <file>")
+	}
+
+	previous, err := api.Restore(cfg, args[0])
+	if previous != "" {
+		fmt.Fprintf(os.Stderr, "previous database saved to %s\n", previous)
+	}
+	if err != nil {
+		return
+	}
+
+	if cfg.Server.DryRun {
+		fmt.Fprintf(os.Stderr, "dry run; %s is a valid backup, and the database was not restored\n", args[0])
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "database restored from %s\n", args[0])
+	return
+}
+
+// handles configuration subcommands
+func configCommand(cfg *config.Configuration, args []string) (err error) {
+	if len(args) != 1 || args[0] != "print" {
+		return errors.New("usage: backend config print")
+	}
+
+	// secrets are never printed
+	out, err := yaml.Marshal(cfg.Redacted())
+	if err != nil {
+		return
+	}
+
+	if !files.Exists(cfg.Path) {
+		fmt.Printf("# %s not found; default values and environment variables are used\n", cfg.Path)
+	}
+	_, err = os.Stdout.Write(out)
+	return
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go-blog/pkg/util/config"
+	"go-blog/pkg/util/template"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rwbm/go-tools/files"
+)
+
+// Lint output formats
+const (
+	LintFormatText   = "text"
+	LintFormatJSON   = "json"
+	LintFormatGitHub = "github"
+)
+
+// result of linting a single template
+type lintResult struct {
+	File        string               `json:"file"`
+	Valid       bool                 `json:"valid"`
+	Error       string               `json:"error,omitempty"`
+	Diagnostics template.Diagnostics `json:"diagnostics"`
+}
+
+// lint validates local templates without touching the database; it runs as the lint and
+// validate commands. If a configuration file is set, with cfgPath or the -config flag, its
+// template settings are applied, as the service does, unless flags override them. Returns
+// the exit code: 0 if all templates are valid, 1 if not, 2 on usage errors.
+func lint(name string, args []string, cfgPath string) int {
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	format := fs.String("format", LintFormatText, "output format: text, json or github")
+	strict := fs.Bool("strict", false, "fail on warnings too")
+	timezone := fs.String("timezone", "UTC", "timezone for template dates without offset")
+	ext := fs.String("ext", ".tpl", "extension of the templates to look for in folders")
+	cfgFile := fs.String("config", cfgPath, "configuration file whose template.strict_validation and template.timezone are applied")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: backend %s [flags] <files or folders>\n", name)
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if *cfgFile != "" {
+		if !files.Exists(*cfgFile) {
+			fmt.Fprintf(os.Stderr, "configuration file '%s' not found\n", *cfgFile)
+			return 2
+		}
+		cfg, errCfg := config.Load(*cfgFile)
+		if errCfg != nil {
+			fmt.Fprintln(os.Stderr, errCfg)
+			return 2
+		}
+
+		set := make(map[string]bool)
+		fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+		if !set["strict"] {
+			*strict = cfg.Template.Strict
+		}
+		if !set["timezone"] {
+			*timezone = cfg.Template.Timezone
+		}
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	if *format != LintFormatText && *format != LintFormatJSON && *format != LintFormatGitHub {
+		fmt.Fprintf(os.Stderr, "invalid format '%s'\n", *format)
+		return 2
+	}
+
+	loc, errLoc := template.LoadLocation(*timezone)
+	if errLoc != nil {
+		fmt.Fprintf(os.Stderr, "invalid timezone '%s': %s\n", *timezone, errLoc)
+		return 2
+	}
+
+	filePaths, errList := listTemplates(fs.Args(), *ext)
+	if errList != nil {
+		fmt.Fprintln(os.Stderr, errList)
+		return 2
+	}
+
+	validator := template.NewValidator(*strict, loc)
+	results := make([]lintResult, 0, len(filePaths))
+	failed := 0
+
+	for _, filePath := range filePaths {
+		res := lintFile(validator, filePath)
+		if !res.Valid {
+			failed++
+		}
+		results = append(results, res)
+	}
+
+	switch *format {
+	case LintFormatJSON:
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		enc.Encode(results)
+	case LintFormatGitHub:
+		printLintGitHub(os.Stdout, results)
+	default:
+		printLintText(os.Stdout, results)
+	}
+
+	fmt.Fprintf(os.Stderr, "%d templates checked, %d failed\n", len(results), failed)
+
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// validates a single template
+func lintFile(validator *template.Validator, filePath string) (res lintResult) {
+	res.File = filePath
+	res.Diagnostics = template.Diagnostics{}
+
+	data, errRead := ioutil.ReadFile(filePath)
+	if errRead != nil {
+		res.Error = errRead.Error()
+		return
+	}
+
+	_, diags, err := validator.Validate(filepath.Base(filePath), string(data))
+	if diags != nil {
+		res.Diagnostics = diags
+	}
+
+	if err != nil {
+		// parse errors are reported as a diagnostic, so all formats show them the same way
+		if errParse, ok := err.(*template.ParseError); ok {
+			res.Diagnostics = append(res.Diagnostics, template.Diagnostic{
+				Field:    "template",
+				Severity: template.SeverityError,
+				Message:  errParse.Message,
+				Line:     errParse.Line,
+				Column:   errParse.Column,
+			})
+		}
+		res.Error = err.Error()
+		return
+	}
+
+	res.Valid = true
+	return
+}
+
+// returns the files to lint; folders are walked looking for templates with the indicated extension
+func listTemplates(args []string, ext string) (filePaths []string, err error) {
+	for _, arg := range args {
+		info, errStat := os.Stat(arg)
+		if errStat != nil {
+			return nil, errStat
+		}
+
+		if !info.IsDir() {
+			filePaths = append(filePaths, arg)
+			continue
+		}
+
+		err = filepath.Walk(arg, func(filePath string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() && filepath.Ext(filePath) == ext {
+				filePaths = append(filePaths, filePath)
+			}
+			return nil
+		})
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// prints diagnostics as file:line:column: severity: field: message; line and
+// column are left out when unknown
+func printLintText(w io.Writer, results []lintResult) {
+	for _, res := range results {
+		if len(res.Diagnostics) == 0 && res.Error != "" {
+			fmt.Fprintf(w, "%s: error: %s\n", res.File, res.Error)
+		}
+
+		for _, d := range res.Diagnostics {
+			position := res.File
+			if d.Line > 0 {
+				position += fmt.Sprintf(":%d:%d", d.Line, d.Column)
+			}
+			fmt.Fprintf(w, "%s: %s: %s: %s\n", position, d.Severity, d.Field, d.Message)
+		}
+	}
+}
+
+// prints diagnostics as GitHub Actions workflow commands, so they show up as annotations
+func printLintGitHub(w io.Writer, results []lintResult) {
+	for _, res := range results {
+		if len(res.Diagnostics) == 0 && res.Error != "" {
+			fmt.Fprintf(w, "::error file=%s::%s\n", githubEscape(res.File, true), githubEscape(res.Error, false))
+		}
+
+		for _, d := range res.Diagnostics {
+			params := "file=" + githubEscape(res.File, true)
+			if d.Line > 0 {
+				params += fmt.Sprintf(",line=%d,col=%d", d.Line, d.Column)
+			}
+			params += ",title=" + githubEscape(d.Field, true)
+
+			fmt.Fprintf(w, "::%s %s::%s\n", d.Severity, params, githubEscape(d.Message, false))
+		}
+	}
+}
+
+// escapes values for GitHub workflow commands
+func githubEscape(s string, property bool) string {
+	s = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+	if property {
+		s = strings.NewReplacer(":", "%3A", ",", "%2C").Replace(s)
+	}
+	return s
+}
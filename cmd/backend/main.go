@@ -1,31 +1,127 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"go-blog/pkg/api"
 	"go-blog/pkg/util/config"
+	"os"
 	"path"
+	"time"
 
+	_ "github.com/jinzhu/gorm/dialects/mysql"
+	_ "github.com/jinzhu/gorm/dialects/postgres"
 	_ "github.com/jinzhu/gorm/dialects/sqlite"
 	"github.com/rwbm/go-tools/files"
 )
 
+// command is a subcommand of the service binary
+type command struct {
+	usage       string
+	description string
+	run         func(cfg *config.Configuration, args []string) error
+}
+
+// available subcommands; lint and validate are handled apart, as the configuration is
+// optional for them
+var commands = map[string]command{
+	"serve":   {"serve [-watch=true]", "start the HTTP server and, unless disabled, the templates watcher", serveCommand},
+	"ingest":  {"ingest <file>", "process a single template and exit", ingestCommand},
+	"watch":   {"watch", "start only the templates watcher, with no HTTP server", watchCommand},
+	"migrate": {"migrate up|down|status", "apply, revert or list database migrations", migrateCommand},
+	"export":  {"export [-format f] [-output file]", "export all posts as JSON lines, or as a zip archive", exportCommand},
+	"import":  {"import [-from source] <path>", "import posts from a file written by export, or from other blog engines", importCommand},
+	"reindex": {"reindex", "clean up categories and tags", reindexCommand},
+	"backup":  {"backup <file>", "write a snapshot of the live database to a file", backupCommand},
+	"restore": {"restore <file>", "replace the database with a backup; the service must be stopped", restoreCommand},
+	"config":  {"config print", "print the effective configuration", configCommand},
+}
+
+// order used to print the commands
+var commandNames = []string{"serve", "ingest", "watch", "migrate", "export", "import", "reindex", "backup", "restore", "config", "lint", "validate"}
+
 func main() {
 
 	defaultConfigFile := path.Join(files.GetAppPath(), "config.yml")
 
+	flag.Usage = usage
 	cfgPath := flag.String("config", defaultConfigFile, "path to configuration file")
 	flag.Parse()
 
+	// no command means serve, as the service always did
+	name, args := "serve", []string{}
+	if flag.NArg() > 0 {
+		name, args = flag.Arg(0), flag.Args()[1:]
+	}
+
+	if name == "lint" || name == "validate" {
+		lintConfig := ""
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name == "config" {
+				lintConfig = *cfgPath
+			}
+		})
+		os.Exit(lint(name, args, lintConfig))
+	}
+
+	cmd, found := commands[name]
+	if !found {
+		fmt.Fprintf(os.Stderr, "unknown command '%s'\n\n", name)
+		usage()
+		os.Exit(2)
+	}
+
+	// the configuration file is optional, unless it's set explicitly
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "config" && !files.Exists(*cfgPath) {
+			checkErr(fmt.Errorf("configuration file '%s' not found", *cfgPath))
+		}
+	})
+
 	cfg, err := config.Load(*cfgPath)
 	checkErr(err)
 
-	// start api server
-	checkErr(api.Start(cfg))
+	closeLog, err := api.SetupLogging(cfg)
+	checkErr(err)
+
+	shutdownTracing, err := api.SetupTracing(cfg)
+	checkErr(err)
+
+	err = cmd.run(cfg, args)
+
+	// pending spans are exported before exiting
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	shutdownTracing(ctx)
+	cancel()
+	closeLog()
+
+	checkErr(err)
+}
+
+// prints the list of commands
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintln(out, "usage: backend [-config file] <command> [arguments]")
+	fmt.Fprintln(out, "\ncommands:")
+	for _, name := range commandNames {
+		if name == "lint" {
+			fmt.Fprintf(out, "  %-28s %s\n", "lint <files or folders>", "validate templates without touching the database")
+			continue
+		}
+		if name == "validate" {
+			fmt.Fprintf(out, "  %-28s %s\n", "validate <files or folders>", "same as lint")
+			continue
+		}
+		fmt.Fprintf(out, "  %-28s %s\n", commands[name].usage, commands[name].description)
+	}
+	fmt.Fprintln(out, "\nflags:")
+	flag.PrintDefaults()
 }
 
 func checkErr(err error) {
 	if err != nil {
-		panic(err.Error())
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,19 @@
+#!/bin/sh
+# run database integration tests against SQLite and a local PostgreSQL container;
+# set GOBLOG_TEST_POSTGRES_DSN to use an existing PostgreSQL server instead
+CONTAINER=go-blog-postgres
+
+if [ -z "$GOBLOG_TEST_POSTGRES_DSN" ]; then
+    docker run -d --rm --name $CONTAINER -p 55432:5432 \
+        -e POSTGRES_USER=blog -e POSTGRES_PASSWORD=blog -e POSTGRES_DB=blog postgres:12 > /dev/null || exit 1
+    trap "docker stop $CONTAINER > /dev/null" EXIT
+
+    # wait until the server accepts connections
+    until docker exec $CONTAINER pg_isready -U blog > /dev/null 2>&1; do
+        sleep 1
+    done
+
+    export GOBLOG_TEST_POSTGRES_DSN="host=127.0.0.1 port=55432 user=blog password=blog dbname=blog sslmode=disable"
+fi
+
+go test -tags integration -count=1 ./pkg/api/post/platform/db/...
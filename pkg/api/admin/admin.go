@@ -0,0 +1,93 @@
+package admin
+
+import (
+	"context"
+	"go-blog/pkg/util/archive"
+	"go-blog/pkg/util/backup"
+	"go-blog/pkg/util/exception"
+	"go-blog/pkg/util/maintenance"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Backup takes a snapshot of the live database into the backups location
+func (a *Admin) Backup(ctx context.Context) (info BackupInfo, err error) {
+
+	file, errBackup := backup.CreateIn(ctx, a.ds, a.backupLocation)
+	if errBackup == backup.ErrUnsupportedDriver {
+		err = echo.NewHTTPError(
+			http.StatusNotImplemented,
+			exception.GetErrorMap(exception.CodeNotImplemented, errBackup.Error()))
+		return
+	}
+	if errBackup != nil {
+		a.logger.Error("error creating backup", errBackup, map[string]interface{}{"path": a.backupLocation})
+
+		err = echo.NewHTTPError(
+			http.StatusInternalServerError,
+			exception.GetErrorMap(exception.CodeInternalServerError, errBackup.Error()))
+
+		return
+	}
+
+	a.logger.Info("backup created", map[string]interface{}{"file": file})
+
+	info.File = file
+	if fi, errStat := os.Stat(file); errStat == nil {
+		info.Size = fi.Size()
+		info.Date = fi.ModTime().UTC()
+	}
+
+	return
+}
+
+// Export writes every post to w as a zip archive in the indicated format; the format must
+// be validated before, as w may have been written when an error is returned
+func (a *Admin) Export(ctx context.Context, w io.Writer, format string) (count int, err error) {
+
+	count, err = archive.Write(ctx, w, format, a.postDB)
+	if err != nil {
+		a.logger.Error("error exporting posts", err, map[string]interface{}{"format": format, "posts": count})
+		return
+	}
+
+	a.logger.Info("posts exported", map[string]interface{}{"format": format, "posts": count})
+	return
+}
+
+// Maintenance returns the status of the maintenance mode
+func (a *Admin) Maintenance() maintenance.Status {
+	return a.maintenance.Status()
+}
+
+// SetMaintenance turns maintenance on or off; while it's on, the API is read-only and
+// templates ingestion is paused
+func (a *Admin) SetMaintenance(enabled bool) (status maintenance.Status, err error) {
+
+	var errSet error
+	if enabled {
+		errSet = a.maintenance.Enable()
+	} else {
+		errSet = a.maintenance.Disable()
+	}
+	if errSet != nil {
+		a.logger.Error("error changing maintenance mode", errSet, map[string]interface{}{"enabled": enabled})
+
+		err = echo.NewHTTPError(
+			http.StatusInternalServerError,
+			exception.GetErrorMap(exception.CodeInternalServerError, errSet.Error()))
+
+		return
+	}
+
+	if enabled {
+		a.logger.Warn("maintenance mode enabled", nil)
+	} else {
+		a.logger.Info("maintenance mode disabled", nil)
+	}
+
+	return a.maintenance.Status(), nil
+}
@@ -0,0 +1,56 @@
+package admin_test
+
+import (
+	"go-blog/pkg/api/admin"
+	"go-blog/pkg/util/log"
+	"go-blog/pkg/util/maintenance"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetMaintenance(t *testing.T) {
+	since := time.Date(2020, 4, 15, 8, 30, 0, 0, time.UTC)
+
+	cases := []struct {
+		name        string
+		enabled     bool
+		set         bool
+		wantEnabled bool
+		wantSince   bool
+	}{
+		{name: "Enable", set: true, wantEnabled: true},
+		{name: "Enable while on", enabled: true, set: true, wantEnabled: true, wantSince: true},
+		{name: "Disable while on", enabled: true},
+		{name: "Disable while off"},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "maintenance")
+			assert.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			file := filepath.Join(dir, "app-mode", "maintenance")
+			mode := maintenance.New(file)
+			if tt.enabled {
+				assert.NoError(t, mode.Enable())
+				assert.NoError(t, os.Chtimes(file, since, since))
+			}
+
+			status, err := admin.Initialize(nil, log.New(), dir, mode).SetMaintenance(tt.set)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantEnabled, status.Enabled)
+			assert.Equal(t, tt.wantEnabled, mode.Enabled())
+
+			// turning maintenance on while it's on keeps the time it started
+			if tt.wantSince {
+				assert.True(t, since.Equal(*status.Since), status.Since)
+			}
+		})
+	}
+}
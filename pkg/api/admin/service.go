@@ -0,0 +1,48 @@
+package admin
+
+import (
+	"context"
+	"go-blog/pkg/api/post/platform/db"
+	"go-blog/pkg/util/log"
+	"go-blog/pkg/util/maintenance"
+	"io"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Service holds the functions declared in the service interface
+type Service interface {
+	Backup(ctx context.Context) (info BackupInfo, err error)
+	Export(ctx context.Context, w io.Writer, format string) (count int, err error)
+	Maintenance() maintenance.Status
+	SetMaintenance(enabled bool) (status maintenance.Status, err error)
+}
+
+// BackupInfo describes a backup file
+type BackupInfo struct {
+	File string    `json:"file"`
+	Size int64     `json:"size"`
+	Date time.Time `json:"date"`
+}
+
+// Admin defines the module for administrative operations
+type Admin struct {
+	ds             *gorm.DB
+	postDB         *db.PostDB
+	logger         *log.Log
+	backupLocation string
+	maintenance    *maintenance.Mode
+}
+
+// Initialize initializes Admin application service; backups are written to backupLocation,
+// and mode is the maintenance mode controlled by the service
+func Initialize(ds *gorm.DB, l *log.Log, backupLocation string, mode *maintenance.Mode) *Admin {
+	return &Admin{
+		ds:             ds,
+		postDB:         db.NewPostDB(ds),
+		logger:         l,
+		backupLocation: backupLocation,
+		maintenance:    mode,
+	}
+}
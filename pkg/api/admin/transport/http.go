@@ -0,0 +1,99 @@
+package transport
+
+import (
+	"fmt"
+	admin "go-blog/pkg/api/admin"
+	"go-blog/pkg/util/archive"
+	"go-blog/pkg/util/exception"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// HTTP represents admin http service
+type HTTP struct {
+	svc admin.Service
+}
+
+// NewHTTP creates new http service to handle requests to the admin group; the group is
+// expected to be protected already
+func NewHTTP(svc admin.Service, g *echo.Group) (h HTTP) {
+	h = HTTP{
+		svc: svc,
+	}
+
+	g.POST("/backup", h.backupHandler)
+	g.GET("/export", h.exportHandler)
+	g.GET("/maintenance", h.maintenanceHandler)
+	g.POST("/maintenance", h.enableMaintenanceHandler)
+	g.DELETE("/maintenance", h.disableMaintenanceHandler)
+
+	return
+}
+
+// --- BACKUP ---
+func (h *HTTP) backupHandler(c echo.Context) error {
+
+	info, err := h.svc.Backup(c.Request().Context())
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, info)
+}
+
+// --- EXPORT ---
+func (h *HTTP) exportHandler(c echo.Context) error {
+
+	format := c.QueryParam("format")
+	if format == "" {
+		format = archive.FormatNDJSON
+	}
+	if !archive.IsFormat(format) {
+		return echo.NewHTTPError(
+			http.StatusBadRequest,
+			exception.GetErrorMap(exception.CodeBadRequest, "format must be one of "+strings.Join(archive.Formats, ", ")))
+	}
+
+	fileName := fmt.Sprintf("blog-export-%s-%s.zip", time.Now().UTC().Format("20060102-150405"), format)
+	c.Response().Header().Set(echo.HeaderContentType, "application/zip")
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="`+fileName+`"`)
+
+	// the status is sent with the first bytes of the archive, so errors found before can
+	// still be returned; once the response started, they can only be logged by the service
+	if _, err := h.svc.Export(c.Request().Context(), c.Response(), format); err != nil && !c.Response().Committed {
+		c.Response().Header().Del(echo.HeaderContentDisposition)
+		return echo.NewHTTPError(
+			http.StatusInternalServerError,
+			exception.GetErrorMap(exception.CodeInternalServerError, err.Error()))
+	}
+
+	return nil
+}
+
+// --- MAINTENANCE ---
+func (h *HTTP) maintenanceHandler(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.svc.Maintenance())
+}
+
+func (h *HTTP) enableMaintenanceHandler(c echo.Context) error {
+
+	status, err := h.svc.SetMaintenance(true)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, status)
+}
+
+func (h *HTTP) disableMaintenanceHandler(c echo.Context) error {
+
+	status, err := h.svc.SetMaintenance(false)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, status)
+}
@@ -0,0 +1,62 @@
+package transport_test
+
+import (
+	"context"
+	"errors"
+	"go-blog/pkg/api/admin"
+	"go-blog/pkg/api/admin/transport"
+	"go-blog/pkg/util/server"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// admin service whose exports write data and then fail with err
+type exportService struct {
+	admin.Service
+	data string
+	err  error
+}
+
+func (s exportService) Export(ctx context.Context, w io.Writer, format string) (count int, err error) {
+	if s.data != "" {
+		if _, err = io.WriteString(w, s.data); err != nil {
+			return
+		}
+	}
+	return 0, s.err
+}
+
+func TestExport(t *testing.T) {
+	cases := []struct {
+		name            string
+		svc             exportService
+		wantStatus      int
+		wantBody        string
+		wantDisposition bool
+	}{
+		{name: "Export", svc: exportService{data: "PK"}, wantStatus: http.StatusOK, wantBody: "PK", wantDisposition: true},
+		{name: "Error before the archive", svc: exportService{err: errors.New("database is locked")}, wantStatus: http.StatusInternalServerError},
+		{name: "Error while sending the archive", svc: exportService{data: "PK", err: errors.New("database is locked")}, wantStatus: http.StatusOK, wantBody: "PK", wantDisposition: true},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			e.HTTPErrorHandler = server.ErrorHandler(e)
+			transport.NewHTTP(tt.svc, e.Group("/admin"))
+
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/export?format=tpl", nil))
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Equal(t, tt.wantDisposition, rec.Header().Get(echo.HeaderContentDisposition) != "")
+			if tt.wantBody != "" {
+				assert.Equal(t, tt.wantBody, rec.Body.String())
+			}
+		})
+	}
+}
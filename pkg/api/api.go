@@ -1,86 +1,506 @@
 package api
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	admin "go-blog/pkg/api/admin"
+	at "go-blog/pkg/api/admin/transport"
 	post "go-blog/pkg/api/post"
+	"go-blog/pkg/api/post/platform/db"
 	pt "go-blog/pkg/api/post/transport"
+	"go-blog/pkg/util/backup"
 	"go-blog/pkg/util/config"
+	"go-blog/pkg/util/health"
+	"go-blog/pkg/util/lifecycle"
 	"go-blog/pkg/util/log"
-	"go-blog/pkg/util/model"
+	"go-blog/pkg/util/maintenance"
+	"go-blog/pkg/util/metrics"
+	"go-blog/pkg/util/migration"
 	"go-blog/pkg/util/server"
 	"go-blog/pkg/util/template"
+	"go-blog/pkg/util/tracing"
 	"go-blog/pkg/util/watcher"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/jinzhu/gorm"
-	"github.com/rwbm/go-tools/files"
+	"github.com/labstack/echo/v4"
 )
 
 // Internal consts
 const (
 	TemplatesExtension = ".tpl"
-	DatabaseDriver     = "sqlite3"
+	DatabaseDriver     = config.DriverSQLite // default driver
 )
 
-// Start starts the API service
+// Start starts the API service, along with the templates watcher
 func Start(cfg *config.Configuration) (err error) {
+	return Serve(cfg, true)
+}
 
-	logger := log.New() // default logger
+// Serve starts the HTTP server; if withWatcher is set, the templates watcher
+// is started too, so templates are processed by the same process. It runs until
+// SIGINT or SIGTERM is received, and then stops every component gracefully.
+func Serve(cfg *config.Configuration, withWatcher bool) (err error) {
 
-	// check if databse exists, so we can recreate it
-	recreateDatabase := false
-	if !files.Exists(cfg.Database.Filename) {
-		recreateDatabase = true
+	// templates folders are only used by the watcher
+	if err = cfg.CheckPaths(withWatcher); err != nil {
+		return
 	}
 
-	// create DB connection
-	ds, errDB := gorm.Open(DatabaseDriver, cfg.Database.Filename)
-	if errDB != nil {
-		return errDB
+	logger := log.New() // default logger
+	lc := lifecycle.New(logger, time.Duration(cfg.Server.ShutdownTimeout)*time.Second)
+	reloader := startReloader(lc, cfg, logger)
+	maintenanceMode := newMaintenanceMode(reloader)
+
+	// the database is closed once everything else stopped
+	ds, err := OpenDatabase(cfg, logger)
+	if err != nil {
+		return
 	}
+	defer ds.Close()
 
-	// create database structure
-	if recreateDatabase {
-		logger.Info("database NOT found; recreating from scratch", map[string]interface{}{"dbfile": cfg.Database.Filename})
-		if errRecreate := ds.AutoMigrate(
-			&model.Post{},
-			&model.PostCategory{},
-			&model.PostTag{}).Error; errRecreate != nil {
-			return errRecreate
+	var fileWatcher *watcher.Watcher
+	if withWatcher {
+		if fileWatcher, err = startWatcher(lc, reloader, maintenanceMode, ds, logger); err != nil {
+			return
 		}
 	}
 
-	// watcher for the templates folder
-	templateProcessor := template.NewProcessor(
-		ds,
-		logger,
-		cfg.Template.ProcessedOK,    // location where templates are moved if processed OK
-		cfg.Template.ProcessedError) // location where templates are moved if processed with ERROR
+	if scheduler := NewBackupScheduler(cfg, ds, logger); scheduler != nil {
+		lc.Go("backup scheduler", func(ctx context.Context) error {
+			scheduler.Start(ctx)
+			return nil
+		})
+	}
 
-	fileWatcher := watcher.NewWatcher(
-		cfg.Template.Base,  // location to look for templates
-		TemplatesExtension, // templates extension to look for
-		time.Duration(cfg.Template.CheckCycle)*time.Second, // interval to check for new templates
-		logger,
-		templateProcessor.ProcessTemplate)
+	// +++++++++++ SERVICES ++++++++++++
 
-	go fileWatcher.Start()
+	if cfg.Server.Name != "" {
+		server.AppName = cfg.Server.Name
+	}
+	server.MaintenanceMode = maintenanceMode
 
-	// +++++++++++ SERVICES ++++++++++++
+	e := newEcho(reloader, maintenanceMode)
+	NewHealth(reloader.Current, ds, fileWatcher).Register(e)
 
-	e := server.New()
+	// number of posts, categories and tags, counted when metrics are scraped
+	if errTotals := metrics.RegisterTotals(db.NewPostDB(ds).Totals); errTotals != nil {
+		logger.Error("error registering metrics", errTotals, nil)
+	}
 	pt.NewHTTP(post.Initialize(ds, nil, logger, cfg.Server.DryRun), e)
 
+	// admin endpoints are only available with the admin token
+	adminGroup := e.Group("/admin", server.AdminAuth(cfg.Server.AdminToken))
+	at.NewHTTP(admin.Initialize(ds, logger, cfg.Backup.Location, maintenanceMode), adminGroup)
+
 	// +++++++++++++++++++++++++++++++++
 
 	// start HTTP server
-	server.Start(e,
-		&server.Config{
-			ServiceName:         cfg.Server.Name,
-			Port:                cfg.Server.Port,
-			ReadTimeoutSeconds:  cfg.Server.ReadTimeout,
-			WriteTimeoutSeconds: cfg.Server.WriteTimeout,
-		},
-		logger)
+	serverConfig := &server.Config{
+		ServiceName:            cfg.Server.Name,
+		Port:                   cfg.Server.Port,
+		ReadTimeoutSeconds:     cfg.Server.ReadTimeout,
+		WriteTimeoutSeconds:    cfg.Server.WriteTimeout,
+		IdleTimeoutSeconds:     cfg.Server.IdleTimeout,
+		MaxHeaderBytes:         cfg.Server.MaxHeaderBytes,
+		KeepAlive:              cfg.Server.KeepAlive,
+		HTTP2:                  cfg.Server.HTTP2,
+		TLSCertFile:            cfg.Server.TLSCertFile,
+		TLSKeyFile:             cfg.Server.TLSKeyFile,
+		Socket:                 cfg.Server.Socket,
+		SocketActivation:       cfg.Server.SocketActivation,
+		ShutdownTimeoutSeconds: cfg.Server.ShutdownTimeout,
+	}
+	lc.Go("http server", func(ctx context.Context) error {
+		return server.Start(ctx, e, serverConfig, logger)
+	})
+
+	return lc.Wait()
+}
+
+// Watch starts only the templates watcher, with no HTTP server; it runs until
+// SIGINT or SIGTERM is received, and then waits for the templates being processed
+func Watch(cfg *config.Configuration) (err error) {
+
+	if err = cfg.CheckPaths(true); err != nil {
+		return
+	}
+
+	logger := log.New() // default logger
+	lc := lifecycle.New(logger, time.Duration(cfg.Server.ShutdownTimeout)*time.Second)
+	reloader := startReloader(lc, cfg, logger)
+	maintenanceMode := newMaintenanceMode(reloader)
+
+	ds, err := OpenDatabase(cfg, logger)
+	if err != nil {
+		return
+	}
+	defer ds.Close()
+
+	if _, err = startWatcher(lc, reloader, maintenanceMode, ds, logger); err != nil {
+		return
+	}
+
+	if scheduler := NewBackupScheduler(cfg, ds, logger); scheduler != nil {
+		lc.Go("backup scheduler", func(ctx context.Context) error {
+			scheduler.Start(ctx)
+			return nil
+		})
+	}
+
+	return lc.Wait()
+}
+
+// starts the templates watcher as a component of lc; once the watcher stops, the
+// templates being processed are given time to end. The watched folders, check cycle
+// and validation settings are updated when the configuration is reloaded. Ingestion is
+// paused while maintenanceMode is on.
+func startWatcher(lc *lifecycle.Manager, reloader *config.Reloader, maintenanceMode *maintenance.Mode, ds *gorm.DB, logger *log.Log) (*watcher.Watcher, error) {
+
+	cfg := reloader.Current()
+	templateProcessor, err := NewProcessor(cfg, ds, logger)
+	if err != nil {
+		return nil, err
+	}
+	fileWatcher := NewWatcher(cfg, templateProcessor, maintenanceMode, logger)
+
+	reloader.OnReload(func(cfg *config.Configuration) {
+		validator, errValidator := newValidator(cfg)
+		if errValidator != nil {
+			logger.Error("error applying template settings", errValidator, nil)
+			return
+		}
+		templateProcessor.Reconfigure(validator, cfg.Template.ProcessedOK, cfg.Template.ProcessedError)
+		fileWatcher.Reconfigure(cfg.Template.Base, time.Duration(cfg.Template.CheckCycle)*time.Second)
+	})
+
+	lc.OnStop("template processor", templateProcessor.Stop)
+	lc.Go("watcher", func(ctx context.Context) error {
+		fileWatcher.Start(ctx)
+		return nil
+	})
+
+	return fileWatcher, nil
+}
+
+// starts the reloader of the configuration as a component of lc; the configuration is
+// reloaded on SIGHUP, and each time the file changes
+func startReloader(lc *lifecycle.Manager, cfg *config.Configuration, logger *log.Log) *config.Reloader {
+
+	reloader := config.NewReloader(cfg, logger)
+	reloader.OnReload(func(cfg *config.Configuration) {
+		log.SetLevel(cfg.Log.Level) // validated by the reloader
+	})
+
+	lc.OnHangup(func() { reloader.Reload() })
+	lc.Go("config reloader", func(ctx context.Context) error {
+		reloader.Start(ctx)
+		return nil
+	})
+
+	return reloader
+}
+
+// creates the maintenance mode controlled by the configured maintenance file, which is
+// changed when the configuration is reloaded
+func newMaintenanceMode(reloader *config.Reloader) *maintenance.Mode {
+
+	maintenanceMode := maintenance.New(reloader.Current().Server.MaintenanceFile)
+	reloader.OnReload(func(cfg *config.Configuration) {
+		maintenanceMode.SetFile(cfg.Server.MaintenanceFile)
+	})
+
+	return maintenanceMode
+}
+
+// creates the echo instance with the API middlewares; the ones with reloadable settings use
+// the current configuration on each request. Admin endpoints are not limited by the request
+// timeout, as backups and exports may take longer, and they're available during maintenance.
+func newEcho(reloader *config.Reloader, maintenanceMode *maintenance.Mode) *echo.Echo {
+
+	e := server.New()
+	e.Use(server.Reloadable(func() echo.MiddlewareFunc {
+		return server.RequestTimeout(time.Duration(reloader.Current().Server.RequestTimeout)*time.Second, "/admin/")
+	}))
+	e.Use(server.Reloadable(func() echo.MiddlewareFunc {
+		return server.Maintenance(maintenanceMode, time.Duration(reloader.Current().Server.MaintenanceRetryAfter)*time.Second, "/admin/")
+	}))
+
+	return e
+}
+
+// Ingest processes a single template synchronously, the same way the watcher does; it
+// fails while maintenance is on
+func Ingest(cfg *config.Configuration, filePath string) (err error) {
+
+	if maintenance.New(cfg.Server.MaintenanceFile).Enabled() {
+		return errors.New("service is under maintenance; templates can't be ingested")
+	}
+
+	if err = cfg.CheckPaths(true); err != nil {
+		return
+	}
+
+	logger := log.New() // default logger
+
+	ds, err := OpenDatabase(cfg, logger)
+	if err != nil {
+		return
+	}
+	defer ds.Close()
+
+	templateProcessor, err := NewProcessor(cfg, ds, logger)
+	if err != nil {
+		return
+	}
+
+	return templateProcessor.Process(context.Background(), filePath)
+}
+
+// OpenDatabase creates the DB connection; pending migrations are applied,
+// unless they were set to be applied manually
+func OpenDatabase(cfg *config.Configuration, logger *log.Log) (ds *gorm.DB, err error) {
+
+	ds, err = openDatabase(cfg)
+	if err != nil {
+		return
+	}
+
+	if cfg.Server.DryRun {
+		logger.Warn("dry run mode is enabled; no changes will be saved", nil)
+	}
+
+	migrator := NewMigrator(ds, logger)
+
+	// a database with a newer schema than the known one can't be used safely
+	version, err := migrator.Version()
+	if err != nil {
+		ds.Close()
+		return nil, err
+	}
+
+	if version > migrator.Latest() {
+		ds.Close()
+		return nil, fmt.Errorf("database schema version %d is newer than the supported one (%d)", version, migrator.Latest())
+	}
+
+	if version < migrator.Latest() {
+		// migrations change the database, so they are not applied in dry run mode
+		if cfg.Database.ManualMigrations || cfg.Server.DryRun {
+			logger.Warn("database has pending migrations", map[string]interface{}{"dbfile": cfg.Database.Filename, "version": version, "latest": migrator.Latest()})
+			return
+		}
+
+		// update database structure
+		if _, err = migrator.Up(); err != nil {
+			ds.Close()
+			return nil, err
+		}
+	}
 
 	return
 }
+
+// creates the DB connection, with no checks on the database structure, and sets
+// up the connection pool
+func openDatabase(cfg *config.Configuration) (ds *gorm.DB, err error) {
+	if ds, err = openConnection(cfg); err != nil {
+		return
+	}
+
+	// queries are recorded as spans when tracing is enabled
+	tracing.InstrumentDB(ds)
+
+	ds.DB().SetMaxOpenConns(cfg.Database.MaxOpenConns)
+	ds.DB().SetMaxIdleConns(cfg.Database.MaxIdleConns)
+	ds.DB().SetConnMaxLifetime(time.Duration(cfg.Database.ConnMaxLifetime) * time.Second)
+
+	return
+}
+
+// opens the database using the configured driver; SQLite uses the database file
+// name, while the rest of drivers use the DSN
+func openConnection(cfg *config.Configuration) (*gorm.DB, error) {
+	switch cfg.Database.Driver {
+	case "", config.DriverSQLite:
+		return gorm.Open(DatabaseDriver, cfg.Database.Filename+"?"+sqliteParams(cfg).Encode())
+
+	case config.DriverPostgres:
+		return gorm.Open(config.DriverPostgres, cfg.Database.DSN)
+
+	case config.DriverMySQL:
+		// dates can't be scanned unless they are parsed by the driver
+		dsn := cfg.Database.DSN
+		if !strings.Contains(dsn, "parseTime=") {
+			if strings.Contains(dsn, "?") {
+				dsn += "&parseTime=true"
+			} else {
+				dsn += "?parseTime=true"
+			}
+		}
+		return gorm.Open(config.DriverMySQL, dsn)
+	}
+
+	return nil, fmt.Errorf("unsupported database driver '%s'", cfg.Database.Driver)
+}
+
+// returns the SQLite connection parameters; they are applied by the driver on
+// every new connection of the pool
+func sqliteParams(cfg *config.Configuration) url.Values {
+	params := url.Values{}
+
+	// foreign keys are disabled by default on SQLite connections
+	params.Set("_foreign_keys", "1")
+
+	if cfg.Database.JournalMode != "" {
+		params.Set("_journal_mode", cfg.Database.JournalMode)
+	}
+	if cfg.Database.Synchronous != "" {
+		params.Set("_synchronous", cfg.Database.Synchronous)
+	}
+	if cfg.Database.BusyTimeout > 0 {
+		params.Set("_busy_timeout", strconv.Itoa(cfg.Database.BusyTimeout))
+	}
+
+	return params
+}
+
+// SetupLogging sets the level, format and output of the service logs; the returned
+// function closes the log file, if any
+func SetupLogging(cfg *config.Configuration) (close func() error, err error) {
+	return log.Setup(log.Config{
+		Level:      cfg.Log.Level,
+		Format:     cfg.Log.Format,     // json or console
+		Output:     cfg.Log.Output,     // stdout or file
+		File:       cfg.Log.File,       // file written when the output is file
+		MaxSize:    cfg.Log.MaxSize,    // megabytes before the file is rotated
+		MaxAge:     cfg.Log.MaxAge,     // days rotated files are kept
+		MaxBackups: cfg.Log.MaxBackups, // number of rotated files kept
+	})
+}
+
+// SetupTracing sets up the export of traces; the returned function flushes pending
+// spans, and must be called before exiting
+func SetupTracing(cfg *config.Configuration) (shutdown func(ctx context.Context) error, err error) {
+
+	serviceName := cfg.Server.Name
+	if serviceName == "" {
+		serviceName = "go-blog"
+	}
+
+	return tracing.Setup(tracing.Config{
+		ServiceName:    serviceName,
+		ServiceVersion: server.AppVersion,
+		Exporter:       cfg.Tracing.Exporter,    // none, stdout or otlp
+		Endpoint:       cfg.Tracing.Endpoint,    // OTLP/HTTP collector
+		SampleRatio:    cfg.Tracing.SampleRatio, // ratio of traces recorded
+	})
+}
+
+// NewMigrator creates the migrator for the database structure changes
+func NewMigrator(ds *gorm.DB, logger *log.Log) *migration.Migrator {
+	return migration.New(ds, logger, migration.Migrations)
+}
+
+// NewProcessor creates the templates processor, based on the template settings
+func NewProcessor(cfg *config.Configuration, ds *gorm.DB, logger *log.Log) (*template.Processor, error) {
+
+	validator, err := newValidator(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return template.NewProcessor(
+		db.NewPostDB(ds),
+		logger,
+		validator,
+		cfg.Template.ProcessedOK,    // location where templates are moved if processed OK
+		cfg.Template.ProcessedError, // location where templates are moved if processed with ERROR
+		cfg.Server.DryRun), nil      // process templates with no changes to the database or files
+}
+
+// creates the templates validator, based on the template settings
+func newValidator(cfg *config.Configuration) (*template.Validator, error) {
+
+	// default timezone for template dates
+	loc, errLoc := template.LoadLocation(cfg.Template.Timezone)
+	if errLoc != nil {
+		return nil, errLoc
+	}
+
+	return template.NewValidator(cfg.Template.Strict, loc), nil // reject templates with warnings in strict mode
+}
+
+// NewBackupScheduler creates the scheduler of database backups; returns nil if
+// scheduled backups are disabled, or not supported by the database driver
+func NewBackupScheduler(cfg *config.Configuration, ds *gorm.DB, logger *log.Log) *backup.Scheduler {
+	if cfg.Backup.Interval <= 0 {
+		return nil
+	}
+
+	if ds.Dialect().GetName() != config.DriverSQLite {
+		logger.Warn("scheduled backups are only supported for sqlite3 databases", map[string]interface{}{"driver": cfg.Database.Driver})
+		return nil
+	}
+
+	return backup.NewScheduler(
+		ds,
+		cfg.Backup.Location, // location where backups are written
+		time.Duration(cfg.Backup.Interval)*time.Minute, // interval between backups
+		cfg.Backup.Retention,                           // number of backups to keep
+		logger)
+}
+
+// NewHealth creates the readiness and liveness probes; readiness checks the database and
+// the free disk space and, if fileWatcher is set, the watcher and the templates folders,
+// as set in the current configuration
+func NewHealth(current func() *config.Configuration, ds *gorm.DB, fileWatcher *watcher.Watcher) *health.Health {
+
+	cfg := current()
+	h := health.New(cfg.Server.Name, server.AppVersion, health.DefaultTimeout)
+	h.Add("database", health.Database(ds.DB()))
+
+	if fileWatcher != nil {
+		h.Add("watcher", func(ctx context.Context) (map[string]interface{}, error) {
+			if !fileWatcher.Alive() {
+				return nil, errors.New("templates folder is not being watched")
+			}
+			return nil, nil
+		})
+		h.Add("folders", func(ctx context.Context) (map[string]interface{}, error) {
+			cfg := current()
+			return health.Writable(cfg.Template.Base, cfg.Template.ProcessedOK, cfg.Template.ProcessedError)(ctx)
+		})
+	}
+
+	// folders where files are written
+	h.Add("disk", func(ctx context.Context) (map[string]interface{}, error) {
+		var paths []string
+		if ds.Dialect().GetName() == config.DriverSQLite {
+			paths = append(paths, filepath.Dir(cfg.Database.Filename))
+		}
+		if fileWatcher != nil {
+			paths = append(paths, current().Template.Base)
+		}
+		return health.DiskSpace(uint64(cfg.Server.MinFreeSpace)<<20, paths...)(ctx)
+	})
+
+	return h
+}
+
+// NewWatcher creates the watcher for the templates folder, which sends the templates
+// found to templateProcessor while maintenanceMode is off
+func NewWatcher(cfg *config.Configuration, templateProcessor *template.Processor, maintenanceMode *maintenance.Mode, logger *log.Log) *watcher.Watcher {
+	return watcher.NewWatcher(
+		cfg.Template.Base,  // location to look for templates
+		TemplatesExtension, // templates extension to look for
+		time.Duration(cfg.Template.CheckCycle)*time.Second, // interval to check for new templates
+		logger,
+		templateProcessor.ProcessTemplate,
+		maintenanceMode.Enabled) // ingestion is paused during maintenance
+}
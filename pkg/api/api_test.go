@@ -0,0 +1,74 @@
+package api
+
+import (
+	"context"
+	"go-blog/pkg/api/admin"
+	at "go-blog/pkg/api/admin/transport"
+	"go-blog/pkg/util/config"
+	"go-blog/pkg/util/log"
+	"go-blog/pkg/util/maintenance"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// admin service whose tasks take longer than the request timeout
+type slowAdmin struct {
+	admin.Service
+	duration time.Duration
+}
+
+func (s slowAdmin) Backup(ctx context.Context) (info admin.BackupInfo, err error) {
+	select {
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-time.After(s.duration):
+		info.File = "blog.db"
+	}
+	return
+}
+
+func (s slowAdmin) Export(ctx context.Context, w io.Writer, format string) (count int, err error) {
+	return
+}
+
+func TestRequestTimeout(t *testing.T) {
+	cfg := &config.Configuration{Path: filepath.Join("testdata", "missing.yml")}
+	cfg.Server.RequestTimeout = 1
+	timeout := time.Second
+
+	cases := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+	}{
+		{name: "Backup", method: http.MethodPost, path: "/admin/backup", wantStatus: http.StatusCreated},
+		{name: "Other requests", method: http.MethodGet, path: "/slow", wantStatus: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newEcho(config.NewReloader(cfg, log.New()), maintenance.New(filepath.Join("testdata", "maintenance")))
+			at.NewHTTP(slowAdmin{duration: timeout + 100*time.Millisecond}, e.Group("/admin"))
+			e.GET("/slow", func(c echo.Context) error {
+				select {
+				case <-c.Request().Context().Done():
+					return echo.NewHTTPError(http.StatusServiceUnavailable)
+				case <-time.After(timeout + 100*time.Millisecond):
+					return c.NoContent(http.StatusOK)
+				}
+			})
+
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+			assert.Equal(t, tt.wantStatus, rec.Code, rec.Body.String())
+		})
+	}
+}
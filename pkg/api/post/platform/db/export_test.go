@@ -0,0 +1,8 @@
+package db
+
+// SetTaxonomyBatchSize changes the number of posts whose taxonomy is loaded with a single
+// query, and returns the previous one
+func SetTaxonomyBatchSize(size int) (previous int) {
+	previous, taxonomyBatchSize = taxonomyBatchSize, size
+	return
+}
@@ -1,21 +1,36 @@
 package db
 
 import (
+	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"go-blog/pkg/util/metrics"
 	"go-blog/pkg/util/model"
+	"go-blog/pkg/util/slug"
+	"go-blog/pkg/util/tracing"
 	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
 )
 
+// layout of the date filters
+const filterDateFormat = "2006-01-02 15:04:05"
+
+// max number of posts whose taxonomy is loaded with a single query, to stay within the
+// bound variables allowed by the database
+var taxonomyBatchSize = 500
+
 type filterResult struct {
-	Query           string
-	Args            []interface{}
-	CategoriesFound bool
-	TagsFound       bool
+	Query string
+	Args  []interface{}
 }
 
 // GetPosts retusn a list of posts based on the indicated filters
-func (p *PostDB) GetPosts(filters map[string]string, pageSize, page int) (posts []model.Post, pag model.Pagination, err error) {
+func (p *PostDB) GetPosts(ctx context.Context, filters map[string]string, pageSize, page int) (posts []model.Post, pag model.Pagination, err error) {
+	defer metrics.ObserveQuery("get_posts", time.Now())
 
 	res := p.buildFilters(filters)
 	sb := strings.Builder{}
@@ -26,16 +41,9 @@ func (p *PostDB) GetPosts(filters map[string]string, pageSize, page int) (posts
 		offset = ((page - 1) * pageSize)
 	}
 
-	// select
-	sb.WriteString("SELECT p.id_post,p.date_created,p.date_updated,p.title,p.author,p.content FROM post p ")
-
-	// joins
-	if res.CategoriesFound {
-		sb.WriteString("INNER JOIN post_category pc ON pc.id_post = p.id_post")
-	}
-	if res.TagsFound {
-		sb.WriteString("INNER JOIN post_tag pt ON pt.id_post = p.id_post")
-	}
+	// select; the query must be portable, as bind vars and pagination are
+	// rendered by the dialect of the connection
+	sb.WriteString("SELECT p.id_post,p.date_created,p.date_updated,p.title,p.author,p.content FROM post p")
 
 	// where
 	if len(res.Query) > 0 {
@@ -45,51 +53,393 @@ func (p *PostDB) GetPosts(filters map[string]string, pageSize, page int) (posts
 	// order
 	sb.WriteString(" ORDER BY p.id_post ASC")
 
-	q := p.ds.Raw(sb.String(), res.Args...).Offset(offset).Limit(pageSize)
-	rows, errQuery := q.Rows()
-	defer rows.Close()
+	err = p.inTransaction(ctx, func(trx *gorm.DB) error {
+		rows, errQuery := trx.Raw(sb.String(), res.Args...).Offset(offset).Limit(pageSize).Rows()
+		if errQuery != nil {
+			return fmt.Errorf("error loading posts: %s", errQuery)
+		}
+		defer rows.Close()
+
+		// get results
+		for rows.Next() {
+			post := model.Post{}
+			if errScan := trx.ScanRows(rows, &post); errScan != nil {
+				return fmt.Errorf("error loading posts: %s", errScan)
+			}
 
-	if errQuery != nil {
-		err = fmt.Errorf("error loading posts: %s", errQuery)
+			// convert content to base64
+			post.Content = p.encodeToBase64(post.Content)
+
+			posts = append(posts, post)
+		}
+		if errRows := rows.Err(); errRows != nil {
+			return fmt.Errorf("error loading posts: %s", errRows)
+		}
+		rows.Close()
+
+		// load categories and tags of the whole page
+		return p.loadTaxonomy(trx, posts)
+	})
+	if err != nil {
+		posts = nil
 		return
 	}
 
-	// get results
-	for rows.Next() {
-		post := model.Post{}
-		if errScan := p.ds.ScanRows(rows, &post); errScan != nil {
-			err = fmt.Errorf("error loading posts: %s", errScan)
+	pag.Page = page
+	pag.PageSize = pageSize
+
+	return
+}
+
+// CreatePost saves a new post in a single transaction, linking it to its categories and
+// tags; categories and tags are matched by name, with no regard to case, and created
+// with a unique slug if they don't exist. Names are taken from CategoryList and TagList
+// if set, or split from Categories and Tags.
+func (p *PostDB) CreatePost(ctx context.Context, post *model.Post) error {
+	defer metrics.ObserveQuery("create_post", time.Now())
+
+	return p.inTransaction(ctx, func(trx *gorm.DB) error {
+		return p.createPost(trx, post)
+	})
+}
+
+// PreviewPost works like CreatePost, but the transaction is always rolled back; the post
+// gets the ID it would be saved with, and constraint errors are returned as if it was
+// saved, so dry runs can report the would-be result without writing anything
+func (p *PostDB) PreviewPost(ctx context.Context, post *model.Post) error {
+	defer metrics.ObserveQuery("preview_post", time.Now())
+
+	return p.inDryRunTransaction(ctx, func(trx *gorm.DB) error {
+		return p.createPost(trx, post)
+	})
+}
+
+// saves the post and its links to categories and tags within trx
+func (p *PostDB) createPost(trx *gorm.DB, post *model.Post) (err error) {
+
+	// save post
+	if err = trx.Create(post).Error; err != nil {
+		return
+	}
+
+	// save categories
+	linked := make(map[int]bool)
+	for _, name := range p.categoryNames(post) {
+		if name = strings.Trim(name, " "); name == "" {
+			continue
+		}
+
+		cat := model.Category{}
+		if err = trx.Where("LOWER(name) = LOWER(?)", name).First(&cat).Error; gorm.IsRecordNotFoundError(err) {
+			cat = model.Category{Name: name}
+			if cat.Slug, err = p.uniqueSlug(trx, cat.TableName(), name); err == nil {
+				err = trx.Create(&cat).Error
+			}
+		}
+		if err != nil {
 			return
 		}
 
-		// convert content to base64
-		post.Content = p.encodeToBase64(post.Content)
+		if linked[cat.ID] {
+			continue
+		}
+		linked[cat.ID] = true
 
-		// load categories
-		cats := []model.PostCategory{}
-		if errGetCats := p.ds.Where("id_post = ?", post.ID).Find(&cats).Error; errGetCats != nil {
-			err = fmt.Errorf("error loading post categories: %s", errGetCats)
+		if err = trx.Create(&model.PostCategory{IDPost: post.ID, IDCategory: cat.ID}).Error; err != nil {
 			return
 		}
-		post.Categories = p.categoriesToString(cats)
+	}
+
+	// save tags
+	linked = make(map[int]bool)
+	for _, name := range p.tagNames(post) {
+		if name = strings.Trim(name, " "); name == "" {
+			continue
+		}
 
-		// load tags
-		tags := []model.PostTag{}
-		if errGetTags := p.ds.Where("id_post = ?", post.ID).Find(&tags).Error; errGetTags != nil {
-			err = fmt.Errorf("error loading post tags: %s", errGetTags)
+		tag := model.Tag{}
+		if err = trx.Where("LOWER(name) = LOWER(?)", name).First(&tag).Error; gorm.IsRecordNotFoundError(err) {
+			tag = model.Tag{Name: name}
+			if tag.Slug, err = p.uniqueSlug(trx, tag.TableName(), name); err == nil {
+				err = trx.Create(&tag).Error
+			}
+		}
+		if err != nil {
 			return
 		}
-		post.Tags = p.tagsToString(tags)
 
-		posts = append(posts, post)
+		if linked[tag.ID] {
+			continue
+		}
+		linked[tag.ID] = true
+
+		if err = trx.Create(&model.PostTag{IDPost: post.ID, IDTag: tag.ID}).Error; err != nil {
+			return
+		}
 	}
 
-	pag.Page = page
-	pag.PageSize = pageSize
+	return
+}
+
+// returns a slug for name that no row of table has yet; names like C++ and C# would
+// get the same slug otherwise
+func (p *PostDB) uniqueSlug(trx *gorm.DB, table, name string) (string, error) {
+	return slug.Unique(name, func(s string) (bool, error) {
+		count := 0
+		err := trx.Table(table).Where("slug = ?", s).Count(&count).Error
+		return count > 0, err
+	})
+}
+
+// ForEachPost loads every post, with its categories and tags, and calls fn for each one
+// of them in ID order; content is not encoded. Iteration stops if fn returns an error.
+// All posts are read within the same transaction, so they are a consistent snapshot.
+func (p *PostDB) ForEachPost(ctx context.Context, fn func(post *model.Post) error) error {
+	defer metrics.ObserveQuery("for_each_post", time.Now())
+
+	return p.inTransaction(ctx, func(trx *gorm.DB) error {
+
+		rows, err := trx.Model(&model.Post{}).Order("id_post ASC").Rows()
+		if err != nil {
+			return fmt.Errorf("error loading posts: %s", err)
+		}
+		defer rows.Close()
+
+		// rows are collected first, so the connection is free to load the taxonomy
+		posts := []model.Post{}
+		for rows.Next() {
+			post := model.Post{}
+			if err = trx.ScanRows(rows, &post); err != nil {
+				return fmt.Errorf("error loading posts: %s", err)
+			}
+			posts = append(posts, post)
+		}
+		if err = rows.Err(); err != nil {
+			return fmt.Errorf("error loading posts: %s", err)
+		}
+		rows.Close()
+
+		for start := 0; start < len(posts); start += taxonomyBatchSize {
+			end := start + taxonomyBatchSize
+			if end > len(posts) {
+				end = len(posts)
+			}
+
+			batch := posts[start:end]
+			if err = p.loadTaxonomy(trx, batch); err != nil {
+				return err
+			}
+
+			for i := range batch {
+				if err = fn(&batch[i]); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// Titles returns the titles of all posts
+func (p *PostDB) Titles(ctx context.Context) (titles []string, err error) {
+	defer metrics.ObserveQuery("titles", time.Now())
+
+	err = p.inTransaction(ctx, func(trx *gorm.DB) error {
+		return trx.Model(&model.Post{}).Order("id_post ASC").Pluck("title", &titles).Error
+	})
+	return
+}
+
+// Totals counts posts, categories and tags
+func (p *PostDB) Totals(ctx context.Context) (totals metrics.Totals, err error) {
+	defer metrics.ObserveQuery("totals", time.Now())
+
+	// counted on each metrics scrape, so no span is recorded
+	err = p.ds.DB().QueryRowContext(ctx,
+		"SELECT (SELECT COUNT(*) FROM post), (SELECT COUNT(*) FROM category), (SELECT COUNT(*) FROM tag)").
+		Scan(&totals.Posts, &totals.Categories, &totals.Tags)
 
 	return
 }
 
+// Reindex cleans up categories and tags: links to posts, categories or tags that don't
+// exist anymore are removed, as well as categories and tags with no posts and no
+// description. Returns the number of rows removed.
+func (p *PostDB) Reindex(ctx context.Context) (removed int, err error) {
+	defer metrics.ObserveQuery("reindex", time.Now())
+
+	err = p.inTransaction(ctx, func(trx *gorm.DB) (errReindex error) {
+		removed, errReindex = p.reindex(trx)
+		return
+	})
+	if err != nil {
+		removed = 0
+	}
+	return
+}
+
+// PreviewReindex returns the number of rows Reindex would remove, with no changes
+func (p *PostDB) PreviewReindex(ctx context.Context) (removed int, err error) {
+	defer metrics.ObserveQuery("preview_reindex", time.Now())
+
+	err = p.inDryRunTransaction(ctx, func(trx *gorm.DB) (errReindex error) {
+		removed, errReindex = p.reindex(trx)
+		return
+	})
+	if err != nil {
+		removed = 0
+	}
+	return
+}
+
+// removes the orphan rows within trx
+func (p *PostDB) reindex(trx *gorm.DB) (removed int, err error) {
+
+	statements := []string{
+		"DELETE FROM post_category WHERE id_post NOT IN (SELECT id_post FROM post) OR id_category NOT IN (SELECT id_category FROM category)",
+		"DELETE FROM post_tag WHERE id_post NOT IN (SELECT id_post FROM post) OR id_tag NOT IN (SELECT id_tag FROM tag)",
+		"DELETE FROM category WHERE description = '' AND id_category NOT IN (SELECT id_category FROM post_category)",
+		"DELETE FROM tag WHERE description = '' AND id_tag NOT IN (SELECT id_tag FROM post_tag)",
+	}
+
+	for _, stmt := range statements {
+
+		// Exec runs no gorm callbacks, so its span is recorded here
+		_, span := tracing.Start(tracing.DBContext(trx), "sql DELETE", semconv.DBStatementKey.String(stmt))
+		res := trx.Exec(stmt)
+		tracing.End(span, res.Error)
+
+		if res.Error != nil {
+			return removed, fmt.Errorf("error reindexing: %s", res.Error)
+		}
+		removed += int(res.RowsAffected)
+	}
+
+	return
+}
+
+// returned by fn to roll back a transaction that succeeded
+var errDryRun = errors.New("dry run")
+
+// runs fn like inTransaction, but the transaction is always rolled back
+func (p *PostDB) inDryRunTransaction(ctx context.Context, fn func(trx *gorm.DB) error) error {
+	err := p.inTransaction(ctx, func(trx *gorm.DB) error {
+		if err := fn(trx); err != nil {
+			return err
+		}
+		return errDryRun
+	})
+	if err == errDryRun {
+		return nil
+	}
+	return err
+}
+
+// runs fn in a transaction bound to ctx, which is committed if fn succeeds. Once ctx
+// is done, the transaction is rolled back and its pending queries fail; in that case,
+// the context error is returned.
+func (p *PostDB) inTransaction(ctx context.Context, fn func(trx *gorm.DB) error) (err error) {
+
+	trx := p.ds.BeginTx(ctx, nil)
+	if trx.Error != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return trx.Error
+	}
+
+	// queries are recorded as children of the span in ctx
+	trx = tracing.WithContext(trx, ctx)
+
+	if err = fn(trx); err == nil {
+		err = trx.Commit().Error
+	} else {
+		trx.Rollback()
+	}
+
+	if err != nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+
+	return
+}
+
+// row with a category or tag linked to a post
+type taxonomyRow struct {
+	IDPost      int    `gorm:"column:id_post"`
+	ID          int    `gorm:"column:id"`
+	Name        string `gorm:"column:name"`
+	Slug        string `gorm:"column:slug"`
+	Description string `gorm:"column:description"`
+}
+
+// loads categories and tags of the posts, running a query for each one of them per
+// batch of taxonomyBatchSize posts; lists are sorted by name
+func (p *PostDB) loadTaxonomy(trx *gorm.DB, posts []model.Post) error {
+
+	for start := 0; start < len(posts); start += taxonomyBatchSize {
+		end := start + taxonomyBatchSize
+		if end > len(posts) {
+			end = len(posts)
+		}
+
+		if err := p.loadTaxonomyBatch(trx, posts[start:end]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// loads categories and tags of a batch of posts, with a single query for each one
+func (p *PostDB) loadTaxonomyBatch(trx *gorm.DB, posts []model.Post) error {
+
+	if len(posts) == 0 {
+		return nil
+	}
+
+	ids := make([]int, len(posts))
+	byID := make(map[int]*model.Post, len(posts))
+	for i := range posts {
+		ids[i] = posts[i].ID
+		byID[posts[i].ID] = &posts[i]
+		posts[i].CategoryList = []model.Category{}
+		posts[i].TagList = []model.Tag{}
+	}
+
+	cats := []taxonomyRow{}
+	if errGetCats := trx.Raw(`SELECT pc.id_post, c.id_category AS id, c.name, c.slug, c.description
+		FROM post_category pc INNER JOIN category c ON c.id_category = pc.id_category
+		WHERE pc.id_post IN (?) ORDER BY c.name`, ids).Scan(&cats).Error; errGetCats != nil {
+		return fmt.Errorf("error loading post categories: %s", errGetCats)
+	}
+
+	for _, r := range cats {
+		post := byID[r.IDPost]
+		post.CategoryList = append(post.CategoryList, model.Category{ID: r.ID, Name: r.Name, Slug: r.Slug, Description: r.Description})
+	}
+
+	tags := []taxonomyRow{}
+	if errGetTags := trx.Raw(`SELECT pt.id_post, t.id_tag AS id, t.name, t.slug, t.description
+		FROM post_tag pt INNER JOIN tag t ON t.id_tag = pt.id_tag
+		WHERE pt.id_post IN (?) ORDER BY t.name`, ids).Scan(&tags).Error; errGetTags != nil {
+		return fmt.Errorf("error loading post tags: %s", errGetTags)
+	}
+
+	for _, r := range tags {
+		post := byID[r.IDPost]
+		post.TagList = append(post.TagList, model.Tag{ID: r.ID, Name: r.Name, Slug: r.Slug, Description: r.Description})
+	}
+
+	for i := range posts {
+		posts[i].Categories = p.categoriesToString(posts[i].CategoryList)
+		posts[i].Tags = p.tagsToString(posts[i].TagList)
+	}
+
+	return nil
+}
+
 func (p *PostDB) buildFilters(filters map[string]string) (result filterResult) {
 
 	filterArgs := []interface{}{}
@@ -110,42 +460,42 @@ func (p *PostDB) buildFilters(filters map[string]string) (result filterResult) {
 
 		case model.FilterDateFrom:
 			sbWhere.WriteString(" p.date_created >= ? AND ")
-			filterArgs = append(filterArgs, v)
+			filterArgs = append(filterArgs, p.parseDateFilter(v))
 
 		case model.FilterDateTo:
 			sbWhere.WriteString(" p.date_created <= ? AND ")
-			filterArgs = append(filterArgs, v)
+			filterArgs = append(filterArgs, p.parseDateFilter(v))
 
 		case model.FilterCategories:
 			if filterValues := p.parseMultipleValuesFilter(v); len(filterValues) > 0 {
 
+				// a subquery avoids duplicated posts when more than one value matches;
+				// values are matched by name, with no regard to case, or slug
 				paramStr := strings.Repeat("?,", len(filterValues))
-				sbWhere.WriteString("pc.name IN ( ")
+				sbWhere.WriteString(" p.id_post IN (SELECT pc.id_post FROM post_category pc")
+				sbWhere.WriteString(" INNER JOIN category c ON c.id_category = pc.id_category WHERE LOWER(c.name) IN (")
 				sbWhere.WriteString(paramStr[0 : len(paramStr)-1])
-				sbWhere.WriteString(") AND ")
+				sbWhere.WriteString(") OR c.slug IN (")
+				sbWhere.WriteString(paramStr[0 : len(paramStr)-1])
+				sbWhere.WriteString(")) AND ")
 
 				// add parameter values
-				for i := range filterValues {
-					filterArgs = append(filterArgs, strings.Trim(filterValues[i], " "))
-				}
-
-				result.CategoriesFound = true
+				filterArgs = append(filterArgs, p.taxonomyFilterArgs(filterValues)...)
 			}
 
 		case model.FilterTags:
 			if filterValues := p.parseMultipleValuesFilter(v); len(filterValues) > 0 {
 
 				paramStr := strings.Repeat("?,", len(filterValues))
-				sbWhere.WriteString("pt.name IN (")
+				sbWhere.WriteString(" p.id_post IN (SELECT pt.id_post FROM post_tag pt")
+				sbWhere.WriteString(" INNER JOIN tag t ON t.id_tag = pt.id_tag WHERE LOWER(t.name) IN (")
+				sbWhere.WriteString(paramStr[0 : len(paramStr)-1])
+				sbWhere.WriteString(") OR t.slug IN (")
 				sbWhere.WriteString(paramStr[0 : len(paramStr)-1])
-				sbWhere.WriteString(") AND ")
+				sbWhere.WriteString(")) AND ")
 
 				// add parameter values
-				for i := range filterValues {
-					filterArgs = append(filterArgs, strings.Trim(filterValues[i], " "))
-				}
-
-				result.TagsFound = true
+				filterArgs = append(filterArgs, p.taxonomyFilterArgs(filterValues)...)
 			}
 
 		}
@@ -158,17 +508,60 @@ func (p *PostDB) buildFilters(filters map[string]string) (result filterResult) {
 	return
 }
 
+// date filters are sent as typed values, so every driver compares them as dates;
+// values that can't be parsed are sent as they are
+func (p *PostDB) parseDateFilter(value string) interface{} {
+	if t, err := time.ParseInLocation(filterDateFormat, value, time.UTC); err == nil {
+		return t
+	}
+	return value
+}
+
+// returns the args to filter categories or tags: trimmed values in lower case, to match
+// names, followed by the same values, to match slugs; slugs are not built from values,
+// as names like C# and C would match each other
+func (p *PostDB) taxonomyFilterArgs(values []string) (args []interface{}) {
+	for i := range values {
+		args = append(args, strings.ToLower(strings.Trim(values[i], " ")))
+	}
+	return append(args, args...)
+}
+
 func (p *PostDB) parseMultipleValuesFilter(values string) []string {
 	result := strings.Split(values, ",")
 	return result
 }
 
+// returns the category names of the post; the comma separated string is only used
+// if the list is not set
+func (p *PostDB) categoryNames(post *model.Post) (names []string) {
+	if len(post.CategoryList) == 0 {
+		return p.parseMultipleValuesFilter(post.Categories)
+	}
+	for i := range post.CategoryList {
+		names = append(names, post.CategoryList[i].Name)
+	}
+	return
+}
+
+// returns the tag names of the post; the comma separated string is only used if the
+// list is not set
+func (p *PostDB) tagNames(post *model.Post) (names []string) {
+	if len(post.TagList) == 0 {
+		return p.parseMultipleValuesFilter(post.Tags)
+	}
+	for i := range post.TagList {
+		names = append(names, post.TagList[i].Name)
+	}
+	return
+}
+
 func (p *PostDB) encodeToBase64(data string) (encoded string) {
 	encoded = base64.StdEncoding.EncodeToString([]byte(data))
 	return
 }
 
-func (p *PostDB) categoriesToString(cats []model.PostCategory) (s string) {
+func (p *PostDB) categoriesToString(cats []model.Category) (s string) {
 	sa := []string{}
 	for i := range cats {
 		sa = append(sa, cats[i].Name)
@@ -177,7 +570,7 @@ func (p *PostDB) categoriesToString(cats []model.PostCategory) (s string) {
 	return strings.Join(sa, ",")
 }
 
-func (p *PostDB) tagsToString(tags []model.PostTag) (s string) {
+func (p *PostDB) tagsToString(tags []model.Tag) (s string) {
 	sa := []string{}
 	for i := range tags {
 		sa = append(sa, tags[i].Name)
@@ -0,0 +1,116 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"go-blog/pkg/util/log"
+	"go-blog/pkg/util/migration"
+	"go-blog/pkg/util/model"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+)
+
+const (
+	benchPosts    = 10000
+	benchPageSize = 100
+)
+
+// opens a SQLite database filled with benchPosts posts, with 3 categories and
+// 5 tags each; the database is removed once the benchmark ends
+func openBenchDatabase(b *testing.B) *gorm.DB {
+	dir, err := ioutil.TempDir("", "go-blog-bench")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { os.RemoveAll(dir) })
+
+	ds, err := gorm.Open("sqlite3", path.Join(dir, "blog.db")+"?_foreign_keys=1")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { ds.Close() })
+
+	if _, err = migration.New(ds, log.New(), migration.Migrations).Up(); err != nil {
+		b.Fatal(err)
+	}
+
+	trx := ds.Begin()
+	for i := 1; i <= 50; i++ {
+		trx.Exec("INSERT INTO category (name, slug) VALUES (?, ?)", fmt.Sprintf("Category %d", i), fmt.Sprintf("category-%d", i))
+		trx.Exec("INSERT INTO tag (name, slug) VALUES (?, ?)", fmt.Sprintf("Tag %d", i), fmt.Sprintf("tag-%d", i))
+	}
+
+	now := time.Now().UTC()
+	for i := 1; i <= benchPosts; i++ {
+		trx.Exec("INSERT INTO post (date_created, date_updated, title, author, content, original_filename) VALUES (?, ?, ?, ?, ?, ?)",
+			now, now, fmt.Sprintf("Post %d", i), "John Doe", "<body>content</body>", "post.tpl")
+		for j := 0; j < 3; j++ {
+			trx.Exec("INSERT INTO post_category (id_post, id_category) VALUES (?, ?)", i, (i+j*7)%50+1)
+		}
+		for j := 0; j < 5; j++ {
+			trx.Exec("INSERT INTO post_tag (id_post, id_tag) VALUES (?, ?)", i, (i+j*3)%50+1)
+		}
+	}
+	if err = trx.Commit().Error; err != nil {
+		b.Fatal(err)
+	}
+
+	return ds
+}
+
+func BenchmarkGetPosts(b *testing.B) {
+	postDB := NewPostDB(openBenchDatabase(b))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		page := i%(benchPosts/benchPageSize) + 1
+		if _, _, err := postDB.GetPosts(context.Background(), map[string]string{}, benchPageSize, page); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// same page loaded with a query per post for categories and tags, as listings
+// did before; kept as a baseline for BenchmarkGetPosts
+func BenchmarkGetPostsPerPostTaxonomy(b *testing.B) {
+	ds := openBenchDatabase(b)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		page := i%(benchPosts/benchPageSize) + 1
+
+		posts := []model.Post{}
+		if err := ds.Order("id_post ASC").Offset((page - 1) * benchPageSize).Limit(benchPageSize).Find(&posts).Error; err != nil {
+			b.Fatal(err)
+		}
+
+		for j := range posts {
+			cats, tags := []model.Category{}, []model.Tag{}
+			if err := ds.Raw(`SELECT c.* FROM category c INNER JOIN post_category pc ON pc.id_category = c.id_category
+				WHERE pc.id_post = ? ORDER BY c.name`, posts[j].ID).Scan(&cats).Error; err != nil {
+				b.Fatal(err)
+			}
+			if err := ds.Raw(`SELECT t.* FROM tag t INNER JOIN post_tag pt ON pt.id_tag = t.id_tag
+				WHERE pt.id_post = ? ORDER BY t.name`, posts[j].ID).Scan(&tags).Error; err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkForEachPost(b *testing.B) {
+	postDB := NewPostDB(openBenchDatabase(b))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := postDB.ForEachPost(context.Background(), func(post *model.Post) error { return nil }); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,313 @@
+//go:build integration
+// +build integration
+
+package db_test
+
+import (
+	"context"
+	"encoding/base64"
+	"go-blog/pkg/api/post/platform/db"
+	"go-blog/pkg/util/config"
+	"go-blog/pkg/util/log"
+	"go-blog/pkg/util/migration"
+	"go-blog/pkg/util/model"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/mysql"
+	_ "github.com/jinzhu/gorm/dialects/postgres"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/stretchr/testify/assert"
+)
+
+// Integration tests run the same scenarios against every database available:
+// SQLite always, PostgreSQL if GOBLOG_TEST_POSTGRES_DSN is set and MySQL if
+// GOBLOG_TEST_MYSQL_DSN is set. Run them with `./integration.sh`.
+func TestPostDBIntegration(t *testing.T) {
+	backends := []struct {
+		driver string
+		dsn    func(t *testing.T) string
+	}{
+		{
+			driver: config.DriverSQLite,
+			dsn: func(t *testing.T) string {
+				dir, err := ioutil.TempDir("", "go-blog")
+				assert.NoError(t, err)
+				return path.Join(dir, "blog.db") + "?_foreign_keys=1"
+			},
+		},
+		{
+			driver: config.DriverPostgres,
+			dsn:    func(t *testing.T) string { return os.Getenv("GOBLOG_TEST_POSTGRES_DSN") },
+		},
+		{
+			driver: config.DriverMySQL,
+			dsn:    func(t *testing.T) string { return os.Getenv("GOBLOG_TEST_MYSQL_DSN") },
+		},
+	}
+
+	for _, backend := range backends {
+		t.Run(backend.driver, func(t *testing.T) {
+			dsn := backend.dsn(t)
+			if dsn == "" {
+				t.Skipf("no DSN set for %s", backend.driver)
+			}
+
+			ds := openTestDatabase(t, backend.driver, dsn)
+			defer ds.Close()
+
+			runScenarios(t, db.NewPostDB(ds), ds)
+		})
+	}
+}
+
+// opens the database and recreates its structure from scratch
+func openTestDatabase(t *testing.T, driver, dsn string) *gorm.DB {
+	ds, err := gorm.Open(driver, dsn)
+	if err != nil {
+		t.Fatalf("error opening %s database: %s", driver, err)
+	}
+
+	m := migration.New(ds, log.New(), migration.Migrations)
+	if _, err = m.Down(len(migration.Migrations)); err != nil {
+		t.Fatalf("error cleaning %s database: %s", driver, err)
+	}
+	if _, err = m.Up(); err != nil {
+		t.Fatalf("error migrating %s database: %s", driver, err)
+	}
+
+	return ds
+}
+
+func runScenarios(t *testing.T, postDB *db.PostDB, ds *gorm.DB) {
+
+	ctx := context.Background()
+
+	posts := []model.Post{
+		{
+			Title:       "First",
+			Author:      "John Doe",
+			Content:     "<body>first</body>",
+			Categories:  "Go Programming",
+			Tags:        "go,web",
+			DateCreated: time.Date(2020, 4, 15, 12, 9, 57, 0, time.UTC),
+			DateUpdated: time.Date(2020, 4, 15, 12, 9, 57, 0, time.UTC),
+		},
+		{
+			Title:       "Second",
+			Author:      "Jane Doe",
+			Content:     "<body>second</body>",
+			Categories:  "AWS",
+			Tags:        "aws,devops",
+			DateCreated: time.Date(2020, 4, 20, 20, 35, 17, 0, time.UTC),
+			DateUpdated: time.Date(2020, 4, 20, 20, 35, 17, 0, time.UTC),
+		},
+		{
+			Title:       "Third",
+			Author:      "John Doe",
+			Content:     "<body>third</body>",
+			Categories:  "Go Programming,AWS",
+			Tags:        "go,aws",
+			DateCreated: time.Date(2020, 5, 2, 9, 0, 0, 0, time.UTC),
+			DateUpdated: time.Date(2020, 5, 2, 9, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for i := range posts {
+		assert.NoError(t, postDB.CreatePost(ctx, &posts[i]))
+		assert.NotZero(t, posts[i].ID)
+	}
+
+	cases := []struct {
+		name      string
+		filters   map[string]string
+		pageSize  int
+		page      int
+		wantTitle []string
+	}{
+		{
+			name:      "No filters",
+			filters:   map[string]string{},
+			wantTitle: []string{"First", "Second", "Third"},
+		},
+		{
+			name:      "Author",
+			filters:   map[string]string{model.FilterAuthor: "John Doe"},
+			wantTitle: []string{"First", "Third"},
+		},
+		{
+			name:      "Tags with no duplicates",
+			filters:   map[string]string{model.FilterTags: "go,aws"},
+			wantTitle: []string{"First", "Second", "Third"},
+		},
+		{
+			name:      "Categories and tags",
+			filters:   map[string]string{model.FilterCategories: "AWS", model.FilterTags: "go"},
+			wantTitle: []string{"Third"},
+		},
+		{
+			name: "Date range",
+			filters: map[string]string{
+				model.FilterDateFrom: "2020-04-16 00:00:00",
+				model.FilterDateTo:   "2020-04-30 23:59:59",
+			},
+			wantTitle: []string{"Second"},
+		},
+		{
+			name:      "Pagination",
+			filters:   map[string]string{},
+			pageSize:  2,
+			page:      2,
+			wantTitle: []string{"Third"},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.pageSize == 0 {
+				tt.pageSize, tt.page = 25, 1
+			}
+
+			got, pag, err := postDB.GetPosts(ctx, tt.filters, tt.pageSize, tt.page)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.page, pag.Page)
+
+			titles := []string{}
+			for i := range got {
+				titles = append(titles, got[i].Title)
+			}
+			assert.Equal(t, tt.wantTitle, titles)
+		})
+	}
+
+	t.Run("Content and taxonomy", func(t *testing.T) {
+		got, _, err := postDB.GetPosts(ctx, map[string]string{model.FilterID: "1"}, 25, 1)
+		assert.NoError(t, err)
+		assert.Len(t, got, 1)
+		assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("<body>first</body>")), got[0].Content)
+		assert.Equal(t, "Go Programming", got[0].Categories)
+		assert.ElementsMatch(t, []string{"go", "web"}, strings.Split(got[0].Tags, ","))
+		assert.True(t, posts[0].DateCreated.Equal(got[0].DateCreated))
+	})
+
+	t.Run("For each post", func(t *testing.T) {
+		count := 0
+		err := postDB.ForEachPost(ctx, func(post *model.Post) error {
+			assert.Equal(t, posts[count].Content, post.Content)
+			count++
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, len(posts), count)
+	})
+
+	t.Run("Taxonomy in batches", func(t *testing.T) {
+		previous := db.SetTaxonomyBatchSize(2)
+		defer db.SetTaxonomyBatchSize(previous)
+
+		got, _, err := postDB.GetPosts(ctx, map[string]string{}, 25, 1)
+		assert.NoError(t, err)
+		if assert.Len(t, got, len(posts)) {
+			for i := range got {
+				assert.NotEmpty(t, got[i].CategoryList, got[i].Title)
+				assert.NotEmpty(t, got[i].TagList, got[i].Title)
+			}
+		}
+	})
+
+	t.Run("Taxonomy matched by name", func(t *testing.T) {
+		post := model.Post{Title: "Fourth", Author: "John Doe", Content: "<body>fourth</body>", Tags: "Go, GO", DateCreated: time.Now(), DateUpdated: time.Now()}
+		assert.NoError(t, postDB.CreatePost(ctx, &post))
+
+		count := 0
+		assert.NoError(t, ds.Model(&model.Tag{}).Where("slug = ?", "go").Count(&count).Error)
+		assert.Equal(t, 1, count)
+		assert.NoError(t, ds.Model(&model.PostTag{}).Where("id_post = ?", post.ID).Count(&count).Error)
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("Taxonomy with the same slug", func(t *testing.T) {
+		post := model.Post{Title: "Sixth", Author: "Jane Doe", Content: "<body>sixth</body>", Categories: "C++, C#, C, c#", DateCreated: time.Now(), DateUpdated: time.Now()}
+		assert.NoError(t, postDB.CreatePost(ctx, &post))
+
+		got, _, err := postDB.GetPosts(ctx, map[string]string{model.FilterID: strconv.Itoa(post.ID)}, 25, 1)
+		assert.NoError(t, err)
+		if assert.Len(t, got, 1) {
+			slugs := map[string]string{}
+			for _, cat := range got[0].CategoryList {
+				slugs[cat.Name] = cat.Slug
+			}
+			assert.Equal(t, map[string]string{"C++": "c", "C#": "c-2", "C": "c-3"}, slugs)
+		}
+
+		// names don't match other categories with the same slug
+		got, _, err = postDB.GetPosts(ctx, map[string]string{model.FilterCategories: "c#"}, 25, 1)
+		assert.NoError(t, err)
+		assert.Len(t, got, 1)
+
+		long := model.Post{Title: "Seventh", Author: "Jane Doe", Content: "<body>seventh</body>", Tags: strings.Repeat("+", 100), DateCreated: time.Now(), DateUpdated: time.Now()}
+		assert.NoError(t, postDB.CreatePost(ctx, &long))
+		tag := model.Tag{}
+		assert.NoError(t, ds.Where("name = ?", strings.Repeat("+", 100)).First(&tag).Error)
+		assert.Len(t, tag.Slug, 128)
+	})
+
+	t.Run("Taxonomy lists", func(t *testing.T) {
+		post := model.Post{Title: "Fifth", Author: "Jane Doe", Content: "<body>fifth</body>", Tags: "ignored", TagList: []model.Tag{{Name: "Rock, Pop"}}, DateCreated: time.Now(), DateUpdated: time.Now()}
+		assert.NoError(t, postDB.CreatePost(ctx, &post))
+
+		got, _, err := postDB.GetPosts(ctx, map[string]string{model.FilterID: strconv.Itoa(post.ID)}, 25, 1)
+		assert.NoError(t, err)
+		assert.Len(t, got, 1)
+		assert.Equal(t, []model.Tag{{ID: got[0].TagList[0].ID, Name: "Rock, Pop", Slug: "rock-pop"}}, got[0].TagList)
+		assert.Empty(t, got[0].CategoryList)
+	})
+
+	t.Run("Totals and previews", func(t *testing.T) {
+		before, err := postDB.Totals(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 7, before.Posts)
+
+		post := model.Post{Title: "Preview", Author: "Jane Doe", Content: "<body></body>", Categories: "Previews", DateCreated: time.Now(), DateUpdated: time.Now()}
+		assert.NoError(t, postDB.PreviewPost(ctx, &post))
+		assert.NotZero(t, post.ID)
+
+		after, err := postDB.Totals(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, before, after)
+	})
+
+	t.Run("Canceled context", func(t *testing.T) {
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		_, _, err := postDB.GetPosts(canceled, map[string]string{}, 25, 1)
+		assert.Equal(t, context.Canceled, err)
+
+		post := model.Post{Title: "Canceled", Author: "Jane Doe", Content: "<body></body>", DateCreated: time.Now(), DateUpdated: time.Now()}
+		assert.Equal(t, context.Canceled, postDB.CreatePost(canceled, &post))
+	})
+
+	t.Run("Reindex", func(t *testing.T) {
+		assert.NoError(t, ds.Create(&model.Tag{Name: "Unused", Slug: "unused"}).Error)
+		assert.NoError(t, ds.Create(&model.Tag{Name: "Described", Slug: "described", Description: "kept"}).Error)
+
+		removed, err := postDB.Reindex(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, removed)
+	})
+
+	t.Run("Cascade on delete", func(t *testing.T) {
+		assert.NoError(t, ds.Delete(&model.Post{ID: posts[0].ID}).Error)
+
+		count := 0
+		assert.NoError(t, ds.Model(&model.PostTag{}).Where("id_post = ?", posts[0].ID).Count(&count).Error)
+		assert.Equal(t, 0, count)
+	})
+}
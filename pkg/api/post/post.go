@@ -1,17 +1,29 @@
 package post
 
 import (
+	"context"
+	"errors"
 	"go-blog/pkg/util/exception"
 	"go-blog/pkg/util/model"
 	"net/http"
 
-	"github.com/labstack/echo"
+	"github.com/labstack/echo/v4"
 )
 
-// GetBlogPosts returns a list of blog posts, with optional filters
-func (p *Post) GetBlogPosts(filters map[string]string, pageSize, page int) (posts []model.Post, pag model.Pagination, err error) {
+// GetBlogPosts returns a list of blog posts, with optional filters; loading is canceled
+// when ctx is done
+func (p *Post) GetBlogPosts(ctx context.Context, filters map[string]string, pageSize, page int) (posts []model.Post, pag model.Pagination, err error) {
 
-	postList, pag, errGet := p.database.GetPosts(filters, pageSize, page)
+	postList, pag, errGet := p.database.GetPosts(ctx, filters, pageSize, page)
+	if errors.Is(errGet, context.DeadlineExceeded) {
+		p.logger.Warn("timeout loading posts from database", nil)
+
+		err = echo.NewHTTPError(
+			http.StatusServiceUnavailable,
+			exception.GetErrorMap(exception.CodeRequestTimeout, ""))
+
+		return
+	}
 	if errGet != nil {
 		p.logger.Error("error loading posts from database", errGet, nil)
 
@@ -0,0 +1,70 @@
+package post_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go-blog/pkg/api/post"
+	"go-blog/pkg/api/post/transport"
+	"go-blog/pkg/util/exception"
+	"go-blog/pkg/util/log"
+	"go-blog/pkg/util/model"
+	"go-blog/pkg/util/server"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// database that fails with err
+type failingDB struct {
+	err error
+}
+
+func (db failingDB) GetPosts(ctx context.Context, filters map[string]string, pageSize, page int) (posts []model.Post, pag model.Pagination, err error) {
+	return nil, pag, db.err
+}
+
+func TestGetBlogPosts(t *testing.T) {
+	cases := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+	}{
+		{name: "Timeout", err: context.DeadlineExceeded, wantStatus: http.StatusServiceUnavailable, wantCode: exception.CodeRequestTimeout},
+		{name: "Database error", err: errors.New("database is locked"), wantStatus: http.StatusInternalServerError, wantCode: exception.CodeInternalServerError},
+		{name: "Posts", wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := post.Initialize(nil, failingDB{err: tt.err}, log.New(), false)
+
+			_, _, err := svc.GetBlogPosts(context.Background(), nil, 10, 1)
+			if tt.err == nil {
+				assert.NoError(t, err)
+			} else if assert.IsType(t, &echo.HTTPError{}, err) {
+				assert.Equal(t, tt.wantStatus, err.(*echo.HTTPError).Code)
+			}
+
+			// the status is kept by the service error handler
+			e := echo.New()
+			e.HTTPErrorHandler = server.ErrorHandler(e)
+			transport.NewHTTP(svc, e)
+
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/posts", nil))
+			assert.Equal(t, tt.wantStatus, rec.Code)
+
+			if tt.wantCode != "" {
+				var body map[string]interface{}
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+				assert.Equal(t, tt.wantCode, body["code"])
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package post
 
 import (
+	"context"
 	"go-blog/pkg/api/post/platform/db"
 	"go-blog/pkg/util/log"
 	"go-blog/pkg/util/model"
@@ -10,12 +11,12 @@ import (
 
 // Service holds the functions delcared in the service interface
 type Service interface {
-	GetBlogPosts(filters map[string]string, pageSize, page int) (posts []model.Post, pag model.Pagination, err error)
+	GetBlogPosts(ctx context.Context, filters map[string]string, pageSize, page int) (posts []model.Post, pag model.Pagination, err error)
 }
 
 // DB holds the functions for database access
 type DB interface {
-	GetPosts(filters map[string]string, pageSize, page int) (posts []model.Post, pag model.Pagination, err error)
+	GetPosts(ctx context.Context, filters map[string]string, pageSize, page int) (posts []model.Post, pag model.Pagination, err error)
 }
 
 // Post defines the module for posts related operations
@@ -7,6 +7,7 @@ import (
 	"go-blog/pkg/util/model"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
@@ -18,6 +19,27 @@ const (
 	DefaultPageSize = 25
 )
 
+// MediaTypeV2 is the Accept profile that selects the v2 response shape on /posts
+const MediaTypeV2 = "application/vnd.goblog.v2+json"
+
+// Term is a category or tag, as returned by the v2 API
+type Term struct {
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+// PostV2 is a post as returned by the v2 API, with categories and tags as lists
+type PostV2 struct {
+	ID          int       `json:"id_post"`
+	DateCreated time.Time `json:"date_created"`
+	DateUpdated time.Time `json:"date_updated"`
+	Title       string    `json:"title"`
+	Author      string    `json:"author"`
+	Content     string    `json:"content"`
+	Categories  []Term    `json:"categories"`
+	Tags        []Term    `json:"tags"`
+}
+
 // HTTP represents auth http service
 type HTTP struct {
 	svc              post.Service
@@ -32,6 +54,7 @@ func NewHTTP(svc post.Service, e *echo.Echo) (h HTTP) {
 	}
 
 	e.GET("/posts", h.getPostsHandler)
+	e.GET("/v2/posts", h.getPostsV2Handler)
 
 	return
 }
@@ -40,6 +63,20 @@ func NewHTTP(svc post.Service, e *echo.Echo) (h HTTP) {
 // --- GET BLOG POSTS ---
 //
 func (h *HTTP) getPostsHandler(c echo.Context) error {
+	c.Response().Header().Add(echo.HeaderVary, echo.HeaderAccept)
+	if strings.Contains(c.Request().Header.Get(echo.HeaderAccept), MediaTypeV2) {
+		return h.getPostsV2Handler(c)
+	}
+	return h.getPosts(c, 1)
+}
+
+func (h *HTTP) getPostsV2Handler(c echo.Context) error {
+	return h.getPosts(c, 2)
+}
+
+// handles the posts listing of both API versions; version 2 renders categories
+// and tags as lists
+func (h *HTTP) getPosts(c echo.Context, version int) error {
 
 	// get pagination data
 	var page, pageSize int
@@ -65,7 +102,13 @@ func (h *HTTP) getPostsHandler(c echo.Context) error {
 		}
 	}
 
-	filters, errFilters := h.buildFilterMap(c)
+	// timezone used to interpret date filters and render dates
+	loc, errLoc := time.LoadLocation(c.QueryParam("tz"))
+	if errLoc != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, exception.GetErrorMap(exception.CodeInvalidTimezone, ""))
+	}
+
+	filters, errFilters := h.buildFilterMap(c, loc)
 	if errFilters != nil {
 		return echo.NewHTTPError(
 			http.StatusBadRequest,
@@ -73,7 +116,7 @@ func (h *HTTP) getPostsHandler(c echo.Context) error {
 	}
 
 	// get posts
-	posts, pageInfo, errPosts := h.svc.GetBlogPosts(filters, pageSize, page)
+	posts, pageInfo, errPosts := h.svc.GetBlogPosts(c.Request().Context(), filters, pageSize, page)
 	if errPosts != nil {
 		return errPosts
 	}
@@ -83,14 +126,54 @@ func (h *HTTP) getPostsHandler(c echo.Context) error {
 		posts = []model.Post{}
 	}
 
+	for i := range posts {
+		posts[i].DateCreated = posts[i].DateCreated.In(loc)
+		posts[i].DateUpdated = posts[i].DateUpdated.In(loc)
+	}
+
 	payload := make(map[string]interface{})
 	payload["posts"] = posts
 	payload["pagination"] = pageInfo
 
+	if version == 2 {
+		postsV2 := make([]PostV2, len(posts))
+		for i := range posts {
+			postsV2[i] = newPostV2(&posts[i])
+		}
+		payload["posts"] = postsV2
+
+		c.Response().Header().Set(echo.HeaderContentType, MediaTypeV2)
+	}
+
 	return c.JSON(http.StatusOK, payload)
 }
 
-func (h *HTTP) buildFilterMap(c echo.Context) (filters map[string]string, err error) {
+// converts a post to its v2 representation
+func newPostV2(post *model.Post) (p PostV2) {
+	p = PostV2{
+		ID:          post.ID,
+		DateCreated: post.DateCreated,
+		DateUpdated: post.DateUpdated,
+		Title:       post.Title,
+		Author:      post.Author,
+		Content:     post.Content,
+		Categories:  []Term{},
+		Tags:        []Term{},
+	}
+
+	for _, cat := range post.CategoryList {
+		p.Categories = append(p.Categories, Term{Name: cat.Name, Slug: cat.Slug})
+	}
+	for _, tag := range post.TagList {
+		p.Tags = append(p.Tags, Term{Name: tag.Name, Slug: tag.Slug})
+	}
+
+	return
+}
+
+// builds the filters from the query params; dates are taken as days in loc,
+// and converted to UTC, as they are stored in the database
+func (h *HTTP) buildFilterMap(c echo.Context, loc *time.Location) (filters map[string]string, err error) {
 	filters = make(map[string]string)
 
 	for k := range c.QueryParams() {
@@ -106,20 +189,18 @@ func (h *HTTP) buildFilterMap(c echo.Context) (filters map[string]string, err er
 				v = v[0:10]
 			}
 
-			_, errParse := time.Parse("2006-01-02", v)
+			day, errParse := time.ParseInLocation("2006-01-02", v, loc)
 			if errParse != nil {
 				err = fmt.Errorf("error parsing date value from '%s'", k)
 				return
 			}
 
-			// add extra time section to fitler in the database
-			timePart := "00:00:00"
+			// filter up to the last second of the day
 			if k == "date-to" {
-				timePart = "23:95:59"
+				day = day.AddDate(0, 0, 1).Add(-time.Second)
 			}
 
-			v += " " + timePart
-			filters[k] = v
+			filters[k] = day.UTC().Format("2006-01-02 15:04:05")
 		}
 
 	}
@@ -0,0 +1,238 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-blog/pkg/api/post/platform/db"
+	"go-blog/pkg/util/archive"
+	"go-blog/pkg/util/backup"
+	"go-blog/pkg/util/config"
+	"go-blog/pkg/util/importer"
+	"go-blog/pkg/util/log"
+	"go-blog/pkg/util/migration"
+	"go-blog/pkg/util/model"
+	"go-blog/pkg/util/template"
+	"io"
+	"os"
+	"strings"
+)
+
+// MigrateUp applies all pending migrations; returns the number of migrations applied
+func MigrateUp(cfg *config.Configuration) (count int, err error) {
+
+	logger := log.New() // default logger
+
+	ds, err := openDatabase(cfg)
+	if err != nil {
+		return
+	}
+	defer ds.Close()
+
+	return NewMigrator(ds, logger).Up()
+}
+
+// MigrateDown reverts the last steps migrations; returns the number of migrations reverted
+func MigrateDown(cfg *config.Configuration, steps int) (count int, err error) {
+
+	logger := log.New() // default logger
+
+	ds, err := openDatabase(cfg)
+	if err != nil {
+		return
+	}
+	defer ds.Close()
+
+	return NewMigrator(ds, logger).Down(steps)
+}
+
+// MigrationStatus returns the list of known migrations, and whether they were applied or not
+func MigrationStatus(cfg *config.Configuration) (status []migration.Status, err error) {
+
+	logger := log.New() // default logger
+
+	ds, err := openDatabase(cfg)
+	if err != nil {
+		return
+	}
+	defer ds.Close()
+
+	return NewMigrator(ds, logger).Status()
+}
+
+// Backup writes a consistent snapshot of the database to dest; it can be taken while
+// the service is running
+func Backup(cfg *config.Configuration, dest string) (err error) {
+
+	ds, err := openDatabase(cfg)
+	if err != nil {
+		return
+	}
+	defer ds.Close()
+
+	return backup.Create(context.Background(), ds, dest)
+}
+
+// Restore replaces the database with the backup in src, once it's validated; the current
+// database is backed up first, and the backup file created is returned. The service
+// must be stopped while restoring. In dry run mode, the backup is only validated.
+func Restore(cfg *config.Configuration, src string) (previous string, err error) {
+
+	if cfg.Database.Driver != config.DriverSQLite {
+		return "", backup.ErrUnsupportedDriver
+	}
+
+	// check the backup before touching the current database
+	if _, err = backup.Check(src); err != nil || cfg.Server.DryRun {
+		return
+	}
+
+	if _, errStat := os.Stat(cfg.Database.Filename); errStat == nil {
+		ds, errOpen := openDatabase(cfg)
+		if errOpen != nil {
+			return "", errOpen
+		}
+
+		previous, err = backup.CreateIn(context.Background(), ds, cfg.Backup.Location)
+		ds.Close()
+		if err != nil {
+			return
+		}
+	}
+
+	latest := NewMigrator(nil, log.New()).Latest()
+	_, err = backup.Restore(src, cfg.Database.Filename, latest)
+
+	return
+}
+
+// Export writes every post to w, one JSON document per line, as written to
+// NDJSON archives
+func Export(cfg *config.Configuration, w io.Writer) (count int, err error) {
+
+	logger := log.New() // default logger
+
+	ds, err := OpenDatabase(cfg, logger)
+	if err != nil {
+		return
+	}
+	defer ds.Close()
+
+	enc := json.NewEncoder(w)
+	err = db.NewPostDB(ds).ForEachPost(context.Background(), func(post *model.Post) error {
+		count++
+		return enc.Encode(archive.NewRecord(post))
+	})
+
+	return
+}
+
+// ExportArchive writes every post to w as a zip archive in the indicated format
+func ExportArchive(cfg *config.Configuration, w io.Writer, format string) (count int, err error) {
+
+	logger := log.New() // default logger
+
+	ds, err := OpenDatabase(cfg, logger)
+	if err != nil {
+		return
+	}
+	defer ds.Close()
+
+	return archive.Write(context.Background(), w, format, db.NewPostDB(ds))
+}
+
+// Import reads posts from r, one JSON document per line as written by Export or
+// found in NDJSON archives, and saves them as new posts. In dry run mode, posts are
+// saved in transactions that are rolled back, and the number of posts that would be
+// imported is returned.
+func Import(cfg *config.Configuration, r io.Reader) (count int, err error) {
+
+	logger := log.New() // default logger
+
+	ds, err := OpenDatabase(cfg, logger)
+	if err != nil {
+		return
+	}
+	defer ds.Close()
+
+	postDB := db.NewPostDB(ds)
+	save := postDB.CreatePost
+	if cfg.Server.DryRun {
+		save = postDB.PreviewPost
+	}
+
+	dec := json.NewDecoder(r)
+
+	for {
+		record := archive.Record{}
+		if err = dec.Decode(&record); err == io.EOF {
+			return count, nil
+		} else if err != nil {
+			return count, fmt.Errorf("error reading post %d: %s", count+1, err)
+		}
+		post := record.ToPost()
+
+		// posts are always imported as new ones
+		post.ID = 0
+		if err = save(context.Background(), &post); err != nil {
+			return count, fmt.Errorf("error saving post '%s': %s", post.Title, err)
+		}
+
+		count++
+	}
+}
+
+// ImportFrom reads the posts of a WordPress, Hugo, Jekyll or RSS source in path, and
+// saves them as new posts; in dry run mode, which is also enabled by the dry_run
+// setting, nothing is saved. Posts are validated with the template rules, and posts
+// without author get defaultAuthor.
+func ImportFrom(cfg *config.Configuration, source, path, defaultAuthor string, dryRun bool) (report importer.Report, err error) {
+
+	read, found := importer.Readers[source]
+	if !found {
+		return report, fmt.Errorf("unsupported import source '%s'; valid sources are %s", source, strings.Join(importer.Sources(), ", "))
+	}
+
+	loc, err := template.LoadLocation(cfg.Template.Timezone)
+	if err != nil {
+		return
+	}
+
+	entries, err := read(path)
+	if err != nil {
+		return
+	}
+
+	logger := log.New() // default logger
+
+	ds, err := OpenDatabase(cfg, logger)
+	if err != nil {
+		return
+	}
+	defer ds.Close()
+
+	return importer.Import(context.Background(), db.NewPostDB(ds), entries, importer.Options{
+		DryRun:        dryRun || cfg.Server.DryRun,
+		DefaultAuthor: defaultAuthor,
+		Validator:     template.NewValidator(cfg.Template.Strict, loc),
+	})
+}
+
+// Reindex cleans up categories and tags; returns the number of rows removed, or the
+// number of rows that would be removed in dry run mode
+func Reindex(cfg *config.Configuration) (removed int, err error) {
+
+	logger := log.New() // default logger
+
+	ds, err := OpenDatabase(cfg, logger)
+	if err != nil {
+		return
+	}
+	defer ds.Close()
+
+	if cfg.Server.DryRun {
+		return db.NewPostDB(ds).PreviewReindex(context.Background())
+	}
+
+	return db.NewPostDB(ds).Reindex(context.Background())
+}
@@ -0,0 +1,252 @@
+package archive
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-blog/pkg/util/model"
+	"go-blog/pkg/util/slug"
+	"go-blog/pkg/util/template"
+	"io"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Supported archive formats
+const (
+	FormatNDJSON   = "ndjson"
+	FormatJSON     = "json"
+	FormatTemplate = "tpl"
+	FormatMarkdown = "markdown"
+)
+
+// Formats contains the supported archive formats
+var Formats = []string{FormatNDJSON, FormatJSON, FormatTemplate, FormatMarkdown}
+
+// ManifestFile is the name of the file describing the archive content
+const ManifestFile = "manifest.json"
+
+// Record is a post as written to JSON exports; categories and tags are included as
+// lists too, so names with commas are kept
+type Record struct {
+	model.Post
+	CategoryList []model.Category `json:"category_list"`
+	TagList      []model.Tag      `json:"tag_list"`
+}
+
+// NewRecord returns the record of a post
+func NewRecord(post *model.Post) Record {
+	r := Record{Post: *post, CategoryList: post.CategoryList, TagList: post.TagList}
+	if r.CategoryList == nil {
+		r.CategoryList = []model.Category{}
+	}
+	if r.TagList == nil {
+		r.TagList = []model.Tag{}
+	}
+	return r
+}
+
+// ToPost returns the post of the record, with categories and tags taken from the lists
+// if they are set
+func (r *Record) ToPost() model.Post {
+	post := r.Post
+	if len(r.CategoryList) > 0 {
+		post.CategoryList = r.CategoryList
+	}
+	if len(r.TagList) > 0 {
+		post.TagList = r.TagList
+	}
+	return post
+}
+
+// Manifest describes the content of an archive
+type Manifest struct {
+	Format string    `json:"format"`
+	Posts  int       `json:"posts"`
+	Date   time.Time `json:"date"`
+}
+
+// Source provides the posts written to archives
+type Source interface {
+	ForEachPost(ctx context.Context, fn func(post *model.Post) error) error
+}
+
+// Write writes every post in src to w, as a zip archive in the indicated format
+func Write(ctx context.Context, w io.Writer, format string, src Source) (count int, err error) {
+
+	a, err := NewWriter(w, format)
+	if err != nil {
+		return
+	}
+
+	if err = src.ForEachPost(ctx, a.Add); err != nil {
+		return
+	}
+
+	return a.Count(), a.Close()
+}
+
+// Writer writes posts to a zip archive; JSON formats are written as a single file, while
+// templates and Markdown are written as a file per post, in the posts folder
+type Writer struct {
+	zw       *zip.Writer
+	format   string
+	entry    io.Writer
+	manifest Manifest
+}
+
+// NewWriter creates a writer of archives in the indicated format to w; Close must be
+// called once all posts are added
+func NewWriter(w io.Writer, format string) (a *Writer, err error) {
+	if !IsFormat(format) {
+		return nil, fmt.Errorf("unsupported export format '%s'; valid formats are %s", format, strings.Join(Formats, ", "))
+	}
+
+	a = &Writer{
+		zw:       zip.NewWriter(w),
+		format:   format,
+		manifest: Manifest{Format: format, Date: time.Now().UTC()},
+	}
+
+	// JSON formats use a single file
+	switch format {
+	case FormatNDJSON:
+		a.entry, err = a.create("posts.ndjson")
+	case FormatJSON:
+		if a.entry, err = a.create("posts.json"); err == nil {
+			_, err = io.WriteString(a.entry, "[")
+		}
+	}
+
+	return
+}
+
+// Add writes the post to the archive; content must not be encoded
+func (a *Writer) Add(post *model.Post) (err error) {
+
+	switch a.format {
+	case FormatNDJSON:
+		err = json.NewEncoder(a.entry).Encode(NewRecord(post))
+
+	case FormatJSON:
+		if a.manifest.Posts > 0 {
+			if _, err = io.WriteString(a.entry, ","); err != nil {
+				return
+			}
+		}
+		var data []byte
+		if data, err = json.Marshal(NewRecord(post)); err == nil {
+			_, err = a.entry.Write(data)
+		}
+
+	case FormatTemplate:
+		err = a.addFile(post, ".tpl", template.FormatTemplate(post))
+
+	case FormatMarkdown:
+		var content string
+		if content, err = Markdown(post); err == nil {
+			err = a.addFile(post, ".md", content)
+		}
+	}
+
+	if err == nil {
+		a.manifest.Posts++
+	}
+	return
+}
+
+// Close writes the manifest and ends the archive; it doesn't close the underlying writer
+func (a *Writer) Close() (err error) {
+
+	if a.format == FormatJSON {
+		if _, err = io.WriteString(a.entry, "]\n"); err != nil {
+			return
+		}
+	}
+
+	f, err := a.create(ManifestFile)
+	if err != nil {
+		return
+	}
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err = enc.Encode(a.manifest); err != nil {
+		return
+	}
+
+	return a.zw.Close()
+}
+
+// Count returns the number of posts added
+func (a *Writer) Count() int {
+	return a.manifest.Posts
+}
+
+// adds a compressed file to the archive, dated when the export started
+func (a *Writer) create(name string) (io.Writer, error) {
+	return a.zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: a.manifest.Date})
+}
+
+// writes a file named after the post ID and title to the posts folder
+func (a *Writer) addFile(post *model.Post, ext, content string) error {
+	f, err := a.create(fmt.Sprintf("posts/%05d-%s%s", post.ID, slug.Make(post.Title), ext))
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(f, content)
+	return err
+}
+
+// IsFormat returns true if format is a supported archive format
+func IsFormat(format string) bool {
+	for _, f := range Formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// front matter of Markdown files
+type frontMatter struct {
+	Title      string   `yaml:"title"`
+	Author     string   `yaml:"author"`
+	Date       string   `yaml:"date"`
+	LastMod    string   `yaml:"lastmod"`
+	Categories []string `yaml:"categories,omitempty"`
+	Tags       []string `yaml:"tags,omitempty"`
+}
+
+// Markdown renders the post as Markdown with YAML front matter. Content is kept
+// as HTML, which Markdown renderers pass through.
+func Markdown(post *model.Post) (string, error) {
+
+	fm := frontMatter{
+		Title:   post.Title,
+		Author:  post.Author,
+		Date:    post.DateCreated.UTC().Format(time.RFC3339),
+		LastMod: post.DateUpdated.UTC().Format(time.RFC3339),
+	}
+	for i := range post.CategoryList {
+		fm.Categories = append(fm.Categories, post.CategoryList[i].Name)
+	}
+	for i := range post.TagList {
+		fm.Tags = append(fm.Tags, post.TagList[i].Name)
+	}
+
+	data, err := yaml.Marshal(fm)
+	if err != nil {
+		return "", err
+	}
+
+	// the <body> tag is not part of the post content
+	content := strings.TrimSpace(post.Content)
+	content = strings.TrimPrefix(content, "<body>")
+	content = strings.TrimSuffix(content, "</body>")
+
+	return "---\n" + string(data) + "---\n\n" + strings.TrimSpace(content) + "\n", nil
+}
@@ -0,0 +1,94 @@
+package archive_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"go-blog/pkg/util/archive"
+	"go-blog/pkg/util/model"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriter(t *testing.T) {
+	posts := []model.Post{
+		{ID: 1, Title: "First Post", Author: "John Doe", Content: "<body><p>first</p></body>", DateCreated: time.Now(), DateUpdated: time.Now(), TagList: []model.Tag{{Name: "Rock, Pop", Slug: "rock-pop"}}},
+		{ID: 2, Title: "Second Post", Author: "Jane Doe", Content: "<body><p>second</p></body>", DateCreated: time.Now(), DateUpdated: time.Now()},
+	}
+
+	cases := []struct {
+		name      string
+		format    string
+		wantFiles []string
+		wantErr   bool
+	}{
+		{name: "NDJSON", format: archive.FormatNDJSON, wantFiles: []string{"posts.ndjson", archive.ManifestFile}},
+		{name: "JSON", format: archive.FormatJSON, wantFiles: []string{"posts.json", archive.ManifestFile}},
+		{name: "Templates", format: archive.FormatTemplate, wantFiles: []string{"posts/00001-first-post.tpl", "posts/00002-second-post.tpl", archive.ManifestFile}},
+		{name: "Markdown", format: archive.FormatMarkdown, wantFiles: []string{"posts/00001-first-post.md", "posts/00002-second-post.md", archive.ManifestFile}},
+		{name: "Unsupported format", format: "xml", wantErr: true},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := bytes.Buffer{}
+			a, err := archive.NewWriter(&buf, tt.format)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+
+			for i := range posts {
+				assert.NoError(t, a.Add(&posts[i]))
+			}
+			assert.NoError(t, a.Close())
+
+			zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			assert.NoError(t, err)
+
+			files := []string{}
+			for _, f := range zr.File {
+				files = append(files, f.Name)
+
+				if f.Name == "posts.json" {
+					rc, _ := f.Open()
+					data, _ := ioutil.ReadAll(rc)
+					records := []archive.Record{}
+					assert.NoError(t, json.Unmarshal(data, &records))
+					assert.Len(t, records, 2)
+					assert.Equal(t, "Rock, Pop", records[0].ToPost().TagList[0].Name)
+				}
+			}
+			assert.Equal(t, tt.wantFiles, files)
+		})
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	post := model.Post{
+		Title:        "First: Post",
+		Author:       "John Doe",
+		DateCreated:  time.Date(2020, 4, 15, 12, 9, 57, 0, time.UTC),
+		DateUpdated:  time.Date(2020, 4, 15, 12, 9, 57, 0, time.UTC),
+		Content:      "<body>\n<p>Hello</p>\n</body>",
+		CategoryList: []model.Category{{Name: "Go Programming"}},
+	}
+
+	got, err := archive.Markdown(&post)
+	assert.NoError(t, err)
+	assert.Equal(t, `---
+title: 'First: Post'
+author: John Doe
+date: "2020-04-15T12:09:57Z"
+lastmod: "2020-04-15T12:09:57Z"
+categories:
+- Go Programming
+---
+
+<p>Hello</p>
+`, got)
+}
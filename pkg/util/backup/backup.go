@@ -0,0 +1,180 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-blog/pkg/util/config"
+	"go-blog/pkg/util/migration"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	// FilePrefix is the prefix of the backups created in a backups folder
+	FilePrefix = "blog-"
+
+	// FileExtension is the extension of the backups created in a backups folder
+	FileExtension = ".db"
+
+	// layout of the timestamp included in backup file names
+	fileTimeFormat = "20060102-150405"
+)
+
+// ErrUnsupportedDriver is returned when backups are requested on a database that isn't SQLite
+var ErrUnsupportedDriver = errors.New("backups are only supported for sqlite3 databases; use the database server tools instead")
+
+// Create writes a consistent snapshot of the live database to dest, using VACUUM INTO, so
+// the database can still be read and written while the backup is taken. The snapshot is
+// written to a temporary file first, so dest is only created if the backup completes.
+func Create(ctx context.Context, ds *gorm.DB, dest string) (err error) {
+
+	if ds.Dialect().GetName() != config.DriverSQLite {
+		return ErrUnsupportedDriver
+	}
+
+	if _, errStat := os.Stat(dest); errStat == nil {
+		return fmt.Errorf("backup file '%s' already exists", dest)
+	}
+
+	tmpFile := dest + ".tmp"
+	os.Remove(tmpFile)
+
+	if _, err = ds.DB().ExecContext(ctx, "VACUUM INTO ?", tmpFile); err != nil {
+		os.Remove(tmpFile)
+		return fmt.Errorf("error creating backup: %s", err)
+	}
+
+	if err = os.Rename(tmpFile, dest); err != nil {
+		os.Remove(tmpFile)
+	}
+
+	return
+}
+
+// CreateIn writes a backup to dir, named after the current time; returns the file created
+func CreateIn(ctx context.Context, ds *gorm.DB, dir string) (file string, err error) {
+
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+
+	file = path.Join(dir, FilePrefix+time.Now().UTC().Format(fileTimeFormat)+FileExtension)
+	err = Create(ctx, ds, file)
+
+	return
+}
+
+// Restore replaces the database file dbFile with the backup in src. The backup must be
+// a valid database with a schema version no newer than latest; older versions are
+// upgraded by migrations on the next start. The service must be stopped while restoring.
+func Restore(src, dbFile string, latest int) (version int, err error) {
+
+	if version, err = Check(src); err != nil {
+		return
+	}
+
+	if version > latest {
+		return version, fmt.Errorf("backup schema version %d is newer than the supported one (%d)", version, latest)
+	}
+
+	// copy next to the database first, so the file is swapped in with a single rename
+	tmpFile := dbFile + ".restore"
+	if err = copyFile(src, tmpFile); err != nil {
+		os.Remove(tmpFile)
+		return
+	}
+
+	if err = os.Rename(tmpFile, dbFile); err != nil {
+		os.Remove(tmpFile)
+		return
+	}
+
+	// journal files of the replaced database don't belong to the restored one
+	os.Remove(dbFile + "-wal")
+	os.Remove(dbFile + "-shm")
+
+	return
+}
+
+// Check verifies the integrity of the database in file, and returns its schema version
+func Check(file string) (version int, err error) {
+
+	if _, err = os.Stat(file); err != nil {
+		return
+	}
+
+	ds, err := gorm.Open(config.DriverSQLite, "file:"+file+"?mode=ro")
+	if err != nil {
+		return 0, fmt.Errorf("'%s' is not a valid database: %s", file, err)
+	}
+	defer ds.Close()
+
+	result := ""
+	if err = ds.DB().QueryRow("PRAGMA integrity_check").Scan(&result); err != nil {
+		return 0, fmt.Errorf("'%s' is not a valid database: %s", file, err)
+	}
+	if result != "ok" {
+		return 0, fmt.Errorf("'%s' failed the integrity check: %s", file, result)
+	}
+
+	if !ds.HasTable(&migration.Record{}) {
+		return 0, fmt.Errorf("'%s' has no schema version", file)
+	}
+
+	err = ds.Model(&migration.Record{}).Select("COALESCE(MAX(version), 0)").Row().Scan(&version)
+	return
+}
+
+// Prune removes the oldest backups in dir, keeping the newest keep ones; returns the
+// files removed. Only files named like the ones created by CreateIn are considered.
+func Prune(dir string, keep int) (removed []string, err error) {
+
+	files, err := filepath.Glob(path.Join(dir, FilePrefix+"*"+FileExtension))
+	if err != nil || len(files) <= keep {
+		return
+	}
+
+	// names include the timestamp, so they sort by date
+	sort.Strings(files)
+
+	for _, file := range files[:len(files)-keep] {
+		if err = os.Remove(file); err != nil {
+			return
+		}
+		removed = append(removed, file)
+	}
+
+	return
+}
+
+// copies the content of src into dst, which is created or truncated
+func copyFile(src, dst string) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return
+	}
+
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return
+	}
+	if err = out.Sync(); err != nil {
+		out.Close()
+		return
+	}
+
+	return out.Close()
+}
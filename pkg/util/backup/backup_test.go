@@ -0,0 +1,94 @@
+package backup_test
+
+import (
+	"context"
+	"go-blog/pkg/util/backup"
+	"go-blog/pkg/util/log"
+	"go-blog/pkg/util/migration"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateAndRestore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-blog-backup")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	dbFile := path.Join(dir, "blog.db")
+	ds, err := gorm.Open("sqlite3", dbFile)
+	assert.NoError(t, err)
+	defer ds.Close()
+
+	migrator := migration.New(ds, log.New(), migration.Migrations)
+	_, err = migrator.Up()
+	assert.NoError(t, err)
+	assert.NoError(t, ds.Exec("INSERT INTO tag (name, slug) VALUES ('Go', 'go')").Error)
+
+	file, err := backup.CreateIn(context.Background(), ds, path.Join(dir, "backups"))
+	assert.NoError(t, err)
+	assert.Error(t, backup.Create(context.Background(), ds, file), "existing files are not overwritten")
+
+	version, err := backup.Check(file)
+	assert.NoError(t, err)
+	assert.Equal(t, migrator.Latest(), version)
+
+	cases := []struct {
+		name    string
+		src     string
+		latest  int
+		wantErr bool
+	}{
+		{name: "Newer schema version", src: file, latest: migrator.Latest() - 1, wantErr: true},
+		{name: "Not a database", src: path.Join(dir, "invalid.db"), latest: migrator.Latest(), wantErr: true},
+		{name: "Valid backup", src: file, latest: migrator.Latest()},
+	}
+
+	assert.NoError(t, ioutil.WriteFile(path.Join(dir, "invalid.db"), []byte("not a database"), 0644))
+	restored := path.Join(dir, "restored.db")
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := backup.Restore(tt.src, restored, tt.latest)
+			if tt.wantErr {
+				assert.Error(t, err)
+				_, errStat := os.Stat(restored)
+				assert.True(t, os.IsNotExist(errStat))
+				return
+			}
+			assert.NoError(t, err)
+
+			restoredDB, err := gorm.Open("sqlite3", restored)
+			assert.NoError(t, err)
+			defer restoredDB.Close()
+
+			count := 0
+			assert.NoError(t, restoredDB.Table("tag").Count(&count).Error)
+			assert.Equal(t, 1, count)
+		})
+	}
+}
+
+func TestPrune(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-blog-backup")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	names := []string{"blog-20200101-000000.db", "blog-20200102-000000.db", "blog-20200103-000000.db", "other.db"}
+	for _, name := range names {
+		assert.NoError(t, ioutil.WriteFile(path.Join(dir, name), nil, 0644))
+	}
+
+	removed, err := backup.Prune(dir, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{path.Join(dir, "blog-20200101-000000.db")}, removed)
+
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 3)
+}
@@ -0,0 +1,72 @@
+package backup
+
+import (
+	"context"
+	"go-blog/pkg/util/log"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// NewScheduler creates a scheduler that writes a backup to dir every interval, keeping
+// the newest retention backups; if retention is 0, old backups are never removed
+func NewScheduler(ds *gorm.DB, dir string, interval time.Duration, retention int, logger *log.Log) *Scheduler {
+	return &Scheduler{
+		ds:        ds,
+		dir:       dir,
+		interval:  interval,
+		retention: retention,
+		logger:    logger,
+	}
+}
+
+// Scheduler takes backups of the database periodically
+type Scheduler struct {
+	ds        *gorm.DB
+	dir       string
+	interval  time.Duration
+	retention int
+	logger    *log.Log
+}
+
+// Start takes backups until ctx is done; a backup being taken at that point is completed
+func (s *Scheduler) Start(ctx context.Context) {
+
+	s.logger.Info("starting scheduled backups on "+s.dir, map[string]interface{}{"interval": s.interval.String(), "retention": s.retention})
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.run()
+		case <-ctx.Done():
+			s.logger.Info("stopping scheduled backups on "+s.dir, nil)
+			return
+		}
+	}
+}
+
+// takes a backup and removes the ones beyond retention
+func (s *Scheduler) run() {
+
+	file, err := CreateIn(context.Background(), s.ds, s.dir)
+	if err != nil {
+		s.logger.Error("error taking scheduled backup", err, map[string]interface{}{"path": s.dir})
+		return
+	}
+	s.logger.Info("scheduled backup created", map[string]interface{}{"file": file})
+
+	if s.retention <= 0 {
+		return
+	}
+
+	removed, err := Prune(s.dir, s.retention)
+	if err != nil {
+		s.logger.Error("error removing old backups", err, map[string]interface{}{"path": s.dir})
+	}
+	for _, file := range removed {
+		s.logger.Info("old backup removed", map[string]interface{}{"file": file})
+	}
+}
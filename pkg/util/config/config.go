@@ -2,44 +2,117 @@ package config
 
 import (
 	"fmt"
+	"go-blog/pkg/util/maintenance"
 	"io/ioutil"
 	"path"
+	"strconv"
 	"strings"
 
 	"github.com/rwbm/go-tools/files"
 	yaml "gopkg.in/yaml.v2"
 )
 
+// Supported database drivers
+const (
+	DriverSQLite   = "sqlite3"
+	DriverPostgres = "postgres"
+	DriverMySQL    = "mysql"
+)
+
 // Configuration is the structure used to hold configuration from config.yml
 type Configuration struct {
 	Server struct {
-		Name         string `yaml:"name"`
-		Port         string `yaml:"port"`
-		ReadTimeout  int    `yaml:"read_timeout"`
-		WriteTimeout int    `yaml:"write_timeout"`
-		DryRun       bool   `yaml:"dry_run"`
+		Name            string `yaml:"name"`
+		Port            string `yaml:"port"`
+		ReadTimeout     int    `yaml:"read_timeout"`
+		WriteTimeout    int    `yaml:"write_timeout"`
+		RequestTimeout  int    `yaml:"request_timeout"`
+		ShutdownTimeout int    `yaml:"shutdown_timeout"`
+		AdminToken      string `yaml:"admin_token" secret:"true"`
+		DryRun          bool   `yaml:"dry_run"`
+		MinFreeSpace    int    `yaml:"min_free_space"`
+
+		MaintenanceFile       string `yaml:"maintenance_file"`
+		MaintenanceRetryAfter int    `yaml:"maintenance_retry_after"`
+
+		IdleTimeout      int    `yaml:"idle_timeout"`
+		MaxHeaderBytes   int    `yaml:"max_header_bytes"`
+		KeepAlive        bool   `yaml:"keep_alive"`
+		HTTP2            bool   `yaml:"http2"`
+		TLSCertFile      string `yaml:"tls_cert_file"`
+		TLSKeyFile       string `yaml:"tls_key_file"`
+		Socket           string `yaml:"socket"`
+		SocketActivation bool   `yaml:"socket_activation"`
 	} `yaml:"server"`
 	Database struct {
-		Filename string `yaml:"filename"`
+		Driver           string `yaml:"driver"`
+		DSN              string `yaml:"dsn" secret:"true"`
+		Filename         string `yaml:"filename"`
+		ManualMigrations bool   `yaml:"manual_migrations"`
+		JournalMode      string `yaml:"journal_mode"`
+		Synchronous      string `yaml:"synchronous"`
+		BusyTimeout      int    `yaml:"busy_timeout"`
+		MaxOpenConns     int    `yaml:"max_open_conns"`
+		MaxIdleConns     int    `yaml:"max_idle_conns"`
+		ConnMaxLifetime  int    `yaml:"conn_max_lifetime"`
 	} `yaml:"database"`
 	Template struct {
 		Base           string `yaml:"base_location"`
 		ProcessedOK    string `yaml:"processed_ok"`
 		ProcessedError string `yaml:"processed_error"`
 		CheckCycle     int    `yaml:"check_cycle"`
+		Strict         bool   `yaml:"strict_validation"`
+		Timezone       string `yaml:"timezone"`
 	} `yaml:"template"`
+	Backup struct {
+		Location  string `yaml:"location"`
+		Interval  int    `yaml:"interval"`
+		Retention int    `yaml:"retention"`
+	} `yaml:"backup"`
+	Tracing struct {
+		Exporter    string  `yaml:"exporter"`
+		Endpoint    string  `yaml:"endpoint"`
+		SampleRatio float64 `yaml:"sample_ratio"`
+	} `yaml:"tracing"`
+	Log struct {
+		Level      string `yaml:"level"`
+		Format     string `yaml:"format"`
+		Output     string `yaml:"output"`
+		File       string `yaml:"file"`
+		MaxSize    int    `yaml:"max_size"`
+		MaxAge     int    `yaml:"max_age"`
+		MaxBackups int    `yaml:"max_backups"`
+	} `yaml:"log"`
+
+	// Path is the file the configuration was loaded from
+	Path string `yaml:"-"`
 }
 
-// Load reads application settings in the indicated file
+// Load reads application settings in the indicated file, which is optional; settings
+// not in the file are read from GOBLOG_* environment variables, or set to their default
+// values. The settings are validated, and an error is returned if any of them is invalid.
 func Load(path string) (cfg *Configuration, err error) {
+	cfg = new(Configuration)
 	if files.Exists(path) {
-		if cfg, err = loadSettingsFromFile(path); err == nil {
-			replaceCustomVars(cfg)
+		if cfg, err = loadSettingsFromFile(path); err != nil {
+			return nil, err
 		}
-	} else {
-		return nil, fmt.Errorf("file '%s' not found", path)
+		if err = expandVars(cfg, files.GetAppPath()); err != nil {
+			return nil, err
+		}
+	}
+
+	// environment variables take precedence over the file
+	if err = applyEnv(cfg); err != nil {
+		return nil, err
 	}
 
+	replaceCustomVars(cfg)
+	cfg.Path = path
+
+	if err = cfg.Validate(); err != nil {
+		return nil, err
+	}
 	return
 }
 
@@ -52,12 +125,54 @@ func replaceCustomVars(cfg *Configuration) {
 	if cfg.Server.Port == "" {
 		cfg.Server.Port = "8080"
 	}
+	if _, errPort := strconv.Atoi(cfg.Server.Port); errPort == nil {
+		cfg.Server.Port = ":" + cfg.Server.Port // a port alone is not a valid address
+	}
+	if cfg.Server.RequestTimeout == 0 {
+		cfg.Server.RequestTimeout = 30 // 30 seconds
+	}
+	if cfg.Server.ShutdownTimeout == 0 {
+		cfg.Server.ShutdownTimeout = 20 // 20 seconds
+	}
+	if cfg.Server.MinFreeSpace == 0 {
+		cfg.Server.MinFreeSpace = 100 // 100 MB
+	}
+	if cfg.Server.MaintenanceFile == "" {
+		cfg.Server.MaintenanceFile = maintenance.DefaultFile
+	} else {
+		cfg.Server.MaintenanceFile = path.Clean(strings.Replace(cfg.Server.MaintenanceFile, "$APP_HOME", appPath, -1))
+	}
+	if cfg.Server.MaintenanceRetryAfter == 0 {
+		cfg.Server.MaintenanceRetryAfter = 120 // 2 minutes
+	}
+	for _, file := range []*string{&cfg.Server.TLSCertFile, &cfg.Server.TLSKeyFile, &cfg.Server.Socket} {
+		if *file != "" {
+			*file = path.Clean(strings.Replace(*file, "$APP_HOME", appPath, -1))
+		}
+	}
 
-	// default DB location and name
+	// default DB driver, location and name
+	if cfg.Database.Driver == "" {
+		cfg.Database.Driver = DriverSQLite
+	}
 	if cfg.Database.Filename == "" {
 		cfg.Database.Filename = "$APP_HOME/blog.db"
 	}
 
+	// default SQLite settings; WAL lets readers work while templates are saved
+	if cfg.Database.JournalMode == "" {
+		cfg.Database.JournalMode = "WAL"
+	}
+	if cfg.Database.Synchronous == "" {
+		cfg.Database.Synchronous = "NORMAL"
+	}
+	if cfg.Database.BusyTimeout == 0 {
+		cfg.Database.BusyTimeout = 5000 // 5 seconds
+	}
+	if cfg.Database.MaxIdleConns == 0 {
+		cfg.Database.MaxIdleConns = 2
+	}
+
 	// default template settings
 	if cfg.Template.Base == "" {
 		cfg.Template.Base = "$APP_HOME/templates"
@@ -71,11 +186,49 @@ func replaceCustomVars(cfg *Configuration) {
 	if cfg.Template.CheckCycle == 0 {
 		cfg.Template.CheckCycle = 30 // 30 seconds
 	}
+	if cfg.Template.Timezone == "" {
+		cfg.Template.Timezone = "UTC"
+	}
+
+	// default backup settings; scheduled backups are disabled unless an interval is set
+	if cfg.Backup.Location == "" {
+		cfg.Backup.Location = "$APP_HOME/backups"
+	}
+
+	// default tracing settings; spans are not recorded unless an exporter is set
+	if cfg.Tracing.Exporter == "" {
+		cfg.Tracing.Exporter = "none"
+	}
+	if cfg.Tracing.Endpoint == "" {
+		cfg.Tracing.Endpoint = "http://localhost:4318"
+	}
+	if cfg.Tracing.SampleRatio <= 0 {
+		cfg.Tracing.SampleRatio = 1 // every trace
+	}
+
+	// default log settings
+	if cfg.Log.Level == "" {
+		cfg.Log.Level = "debug"
+	}
+	if cfg.Log.Format == "" {
+		cfg.Log.Format = "json"
+	}
+	if cfg.Log.Output == "" {
+		cfg.Log.Output = "stdout"
+	}
+	if cfg.Log.File == "" {
+		cfg.Log.File = "$APP_HOME/logs/backend.log"
+	}
+	if cfg.Log.MaxSize == 0 {
+		cfg.Log.MaxSize = 100 // 100 MB
+	}
 
 	cfg.Database.Filename = path.Clean(strings.Replace(cfg.Database.Filename, "$APP_HOME", appPath, -1))
 	cfg.Template.Base = path.Clean(strings.Replace(cfg.Template.Base, "$APP_HOME", appPath, -1))
 	cfg.Template.ProcessedOK = path.Clean(strings.Replace(cfg.Template.ProcessedOK, "$APP_HOME", appPath, -1))
 	cfg.Template.ProcessedError = path.Clean(strings.Replace(cfg.Template.ProcessedError, "$APP_HOME", appPath, -1))
+	cfg.Backup.Location = path.Clean(strings.Replace(cfg.Backup.Location, "$APP_HOME", appPath, -1))
+	cfg.Log.File = path.Clean(strings.Replace(cfg.Log.File, "$APP_HOME", appPath, -1))
 
 }
 
@@ -0,0 +1,151 @@
+package config_test
+
+import (
+	"go-blog/pkg/util/config"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	cases := []struct {
+		name         string
+		content      string
+		env          map[string]string
+		wantErr      string
+		wantPort     string
+		wantToken    string
+		wantCycle    int
+		wantFilename string
+	}{
+		{
+			name:      "Defaults",
+			wantPort:  ":8080",
+			wantCycle: 30,
+		},
+		{
+			name:         "File",
+			content:      "server:\n  port: \"9000\"\n  admin_token: s3cret\ntemplate:\n  check_cycle: 5\ndatabase:\n  filename: /srv/db/blog.db\n",
+			wantPort:     ":9000",
+			wantToken:    "s3cret",
+			wantCycle:    5,
+			wantFilename: "/srv/db/blog.db",
+		},
+		{
+			name:      "Environment overrides",
+			content:   "server:\n  port: :9000\ntemplate:\n  check_cycle: 5\n",
+			env:       map[string]string{"GOBLOG_SERVER_PORT": "127.0.0.1:9090", "GOBLOG_TEMPLATE_CHECK_CYCLE": "10"},
+			wantPort:  "127.0.0.1:9090",
+			wantCycle: 10,
+		},
+		{
+			name:         "Variables",
+			content:      "server:\n  admin_token: ${TEST_TOKEN}\ndatabase:\n  filename: ${TEST_DIR}/blog.db\n",
+			env:          map[string]string{"TEST_TOKEN": "s3cret", "TEST_DIR": "/srv/db"},
+			wantPort:     ":8080",
+			wantToken:    "s3cret",
+			wantCycle:    30,
+			wantFilename: "/srv/db/blog.db",
+		},
+		{
+			name:    "Variable not set",
+			content: "server:\n  admin_token: ${TEST_NOT_SET}\n",
+			wantErr: "server.admin_token refers to environment variable TEST_NOT_SET, which is not set",
+		},
+		{
+			name:    "Invalid environment variable",
+			env:     map[string]string{"GOBLOG_SERVER_DRY_RUN": "maybe"},
+			wantErr: "invalid value of GOBLOG_SERVER_DRY_RUN: 'maybe' is not true or false",
+		},
+		{
+			name:    "Invalid settings",
+			content: "server:\n  port: :http\n  request_timeout: -5\nlog:\n  level: verbose\n",
+			wantErr: "invalid configuration:\n" +
+				"  server.port: ':http' is not a valid address; a port like :8080, or an address like 127.0.0.1:8080, is expected\n" +
+				"  server.request_timeout: must not be negative\n" +
+				"  log.level: 'verbose' is not a level; use debug, info, warn, error",
+		},
+		{
+			name:    "Invalid connection settings",
+			content: "server:\n  tls_key_file: /etc/blog/key.pem\n  http2: true\n  socket: /run/blog.sock\n  socket_activation: true\n",
+			wantErr: "invalid configuration:\n" +
+				"  server.tls_cert_file: must be set along with server.tls_key_file\n" +
+				"  server.http2: needs server.keep_alive, as HTTP/2 connections are kept alive\n" +
+				"  server.socket: can't be used along with server.socket_activation",
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "config")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			file := filepath.Join(dir, "config.yml")
+			if tt.content != "" {
+				require.NoError(t, ioutil.WriteFile(file, []byte(tt.content), 0644))
+			}
+			for name, value := range tt.env {
+				os.Setenv(name, value)
+				defer os.Unsetenv(name)
+			}
+
+			cfg, err := config.Load(file)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, file, cfg.Path)
+			assert.Equal(t, tt.wantPort, cfg.Server.Port)
+			assert.Equal(t, tt.wantToken, cfg.Server.AdminToken)
+			assert.Equal(t, tt.wantCycle, cfg.Template.CheckCycle)
+			if tt.wantFilename == "" {
+				// the default one is in the application folder
+				assert.Equal(t, "blog.db", filepath.Base(cfg.Database.Filename))
+			} else {
+				assert.Equal(t, tt.wantFilename, cfg.Database.Filename)
+			}
+		})
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := &config.Configuration{}
+	cfg.Server.AdminToken = "s3cret"
+	cfg.Server.Name = "blog"
+
+	redacted := cfg.Redacted()
+
+	assert.Equal(t, config.RedactedValue, redacted.Server.AdminToken)
+	assert.Equal(t, "", redacted.Database.DSN) // not set
+	assert.Equal(t, "blog", redacted.Server.Name)
+	assert.Equal(t, "s3cret", cfg.Server.AdminToken)
+}
+
+func TestCheckPaths(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cfg := &config.Configuration{}
+	cfg.Database.Driver = config.DriverSQLite
+	cfg.Database.Filename = filepath.Join(dir, "blog.db")
+	cfg.Backup.Location = filepath.Join(dir, "backups", "daily") // created when needed
+	cfg.Template.Base = filepath.Join(dir, "templates")
+	cfg.Template.ProcessedOK = dir
+	cfg.Template.ProcessedError = dir
+
+	assert.NoError(t, cfg.CheckPaths(false))
+
+	err = cfg.CheckPaths(true)
+	if assert.IsType(t, &config.ValidationError{}, err) {
+		assert.Len(t, err.(*config.ValidationError).Problems, 1)
+		assert.Contains(t, err.Error(), "template.base_location")
+	}
+}
@@ -0,0 +1,175 @@
+package config
+
+import (
+	"context"
+	"go-blog/pkg/util/log"
+	"os"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ReloadCheckInterval is the time between checks for changes to the configuration file
+const ReloadCheckInterval = 5 * time.Second
+
+// Reloadable lists the settings that are applied while the service runs when the
+// configuration is reloaded; changes to the rest need a restart
+var Reloadable = []string{
+	"log.level",
+	"server.request_timeout",
+	"server.maintenance_file",
+	"server.maintenance_retry_after",
+	"template.base_location",
+	"template.processed_ok",
+	"template.processed_error",
+	"template.check_cycle",
+	"template.strict_validation",
+	"template.timezone",
+}
+
+// Reloader reloads the configuration file, applying the reloadable settings to the
+// configuration in use, which is shared by the components of the service
+type Reloader struct {
+	logger  *log.Log
+	initial *Configuration
+	current atomic.Value // *Configuration
+
+	// reloads are serialized, and the file is reloaded only if it changed since the
+	// last time it was checked
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	hooks   []func(cfg *Configuration)
+}
+
+// NewReloader creates a reloader for the configuration loaded from cfg.Path
+func NewReloader(cfg *Configuration, logger *log.Log) *Reloader {
+	r := &Reloader{
+		logger:  logger,
+		initial: cfg,
+	}
+	r.current.Store(cfg)
+	r.modTime, r.size = fileState(cfg.Path)
+
+	return r
+}
+
+// Current returns the configuration in use; the returned value must not be changed
+func (r *Reloader) Current() *Configuration {
+	return r.current.Load().(*Configuration)
+}
+
+// OnReload registers a function that applies the reloadable settings of cfg; it's called
+// each time any of them changes
+func (r *Reloader) OnReload(apply func(cfg *Configuration)) {
+	r.mu.Lock()
+	r.hooks = append(r.hooks, apply)
+	r.mu.Unlock()
+}
+
+// Start reloads the configuration each time the file changes, until ctx is done
+func (r *Reloader) Start(ctx context.Context) {
+	ticker := time.NewTicker(ReloadCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.mu.Lock()
+			modTime, size := fileState(r.initial.Path)
+			changed := !modTime.Equal(r.modTime) || size != r.size
+			r.mu.Unlock()
+
+			if changed {
+				r.logger.Info("configuration file changed; reloading it", map[string]interface{}{"file": r.initial.Path})
+				r.Reload()
+			}
+
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Reload loads the configuration file again, and applies the reloadable settings that
+// changed; the settings that need a restart are logged. If the configuration is not
+// valid, the one in use is kept.
+func (r *Reloader) Reload() (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.modTime, r.size = fileState(r.initial.Path)
+
+	loaded, err := Load(r.initial.Path)
+	if err != nil {
+		r.logger.Error("error reloading configuration; the current one is kept", err, map[string]interface{}{"file": r.initial.Path})
+		return
+	}
+
+	// settings that need a restart are compared with the ones the service started with
+	var changed, restart []string
+	for _, name := range Changes(r.Current(), loaded) {
+		if isReloadable(name) {
+			changed = append(changed, name)
+		}
+	}
+	for _, name := range Changes(r.initial, loaded) {
+		if !isReloadable(name) {
+			restart = append(restart, name)
+		}
+	}
+
+	if len(restart) > 0 {
+		r.logger.Warn("some settings changed, but they need a restart to be applied", map[string]interface{}{"settings": restart})
+	}
+	if len(changed) == 0 {
+		r.logger.Info("configuration reloaded; no reloadable settings changed", nil)
+		return
+	}
+
+	next := *r.Current()
+	for _, name := range changed {
+		field(&next, name).Set(field(loaded, name))
+	}
+	r.current.Store(&next)
+
+	for _, apply := range r.hooks {
+		apply(&next)
+	}
+
+	r.logger.Info("configuration reloaded", map[string]interface{}{"changed": changed})
+	return
+}
+
+// Changes returns the settings with different values in a and b, by their YAML path,
+// like template.check_cycle
+func Changes(a, b *Configuration) (names []string) {
+	for _, name := range settingNames(reflect.TypeOf(*a), "") {
+		if !reflect.DeepEqual(field(a, name).Interface(), field(b, name).Interface()) {
+			names = append(names, name)
+		}
+	}
+	return
+}
+
+func isReloadable(name string) bool {
+	return contains(Reloadable, name)
+}
+
+func contains(values []string, value string) bool {
+	for i := range values {
+		if values[i] == value {
+			return true
+		}
+	}
+	return false
+}
+
+// returns the modification time and size of file, or zero values if it can't be read
+func fileState(file string) (modTime time.Time, size int64) {
+	if info, err := os.Stat(file); err == nil {
+		return info.ModTime(), info.Size()
+	}
+	return
+}
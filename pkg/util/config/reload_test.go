@@ -0,0 +1,107 @@
+package config_test
+
+import (
+	"go-blog/pkg/util/config"
+	"go-blog/pkg/util/log"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const initialConfig = `
+server:
+  port: :8080
+template:
+  base_location: /srv/templates
+  check_cycle: 30
+`
+
+func TestReload(t *testing.T) {
+	cases := []struct {
+		name          string
+		content       string
+		wantErr       bool
+		wantApplied   bool
+		wantPort      string
+		wantBase      string
+		wantCheckTime int
+	}{
+		{
+			name:          "Reloadable settings",
+			content:       "server:\n  port: :8080\ntemplate:\n  base_location: /srv/other\n  check_cycle: 5\n",
+			wantApplied:   true,
+			wantPort:      ":8080",
+			wantBase:      "/srv/other",
+			wantCheckTime: 5,
+		},
+		{
+			name:          "Settings that need a restart",
+			content:       "server:\n  port: :9090\ntemplate:\n  base_location: /srv/templates\n  check_cycle: 30\n",
+			wantPort:      ":8080",
+			wantBase:      "/srv/templates",
+			wantCheckTime: 30,
+		},
+		{
+			name:          "Invalid file",
+			content:       "template:\n  check_cycle: [5]\n",
+			wantErr:       true,
+			wantPort:      ":8080",
+			wantBase:      "/srv/templates",
+			wantCheckTime: 30,
+		},
+		{
+			name:          "Invalid setting",
+			content:       "template:\n  timezone: Mars/Olympus_Mons\n",
+			wantErr:       true,
+			wantPort:      ":8080",
+			wantBase:      "/srv/templates",
+			wantCheckTime: 30,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "config")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			file := filepath.Join(dir, "config.yml")
+			require.NoError(t, ioutil.WriteFile(file, []byte(initialConfig), 0644))
+
+			cfg, err := config.Load(file)
+			require.NoError(t, err)
+
+			reloader := config.NewReloader(cfg, log.New())
+			applied := false
+			reloader.OnReload(func(cfg *config.Configuration) { applied = true })
+
+			require.NoError(t, ioutil.WriteFile(file, []byte(tt.content), 0644))
+			err = reloader.Reload()
+
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.wantApplied, applied)
+
+			current := reloader.Current()
+			assert.Equal(t, tt.wantPort, current.Server.Port)
+			assert.Equal(t, tt.wantBase, current.Template.Base)
+			assert.Equal(t, tt.wantCheckTime, current.Template.CheckCycle)
+
+			// the configuration loaded at first is not changed
+			assert.Equal(t, "/srv/templates", cfg.Template.Base)
+		})
+	}
+}
+
+func TestChanges(t *testing.T) {
+	a, b := &config.Configuration{}, &config.Configuration{}
+	b.Server.Port = ":9090"
+	b.Template.CheckCycle = 5
+	b.Path = "ignored.yml"
+
+	assert.Equal(t, []string{"server.port", "template.check_cycle"}, config.Changes(a, b))
+	assert.Empty(t, config.Changes(a, a))
+}
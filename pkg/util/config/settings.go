@@ -0,0 +1,165 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// EnvPrefix is the prefix of the environment variables that override settings, like
+// GOBLOG_SERVER_PORT for server.port
+const EnvPrefix = "GOBLOG_"
+
+// RedactedValue replaces secrets in the configuration returned by Redacted
+const RedactedValue = "<redacted>"
+
+// references to environment variables in settings, like ${DB_PASSWORD}
+var varPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// EnvName returns the environment variable that overrides a setting, by its YAML path
+func EnvName(name string) string {
+	return EnvPrefix + strings.ToUpper(strings.Replace(name, ".", "_", -1))
+}
+
+// Redacted returns a copy of cfg with the secrets, like the admin token, replaced by
+// RedactedValue; secrets not set are left empty
+func (cfg *Configuration) Redacted() *Configuration {
+	redacted := *cfg
+	for _, name := range settingNames(reflect.TypeOf(redacted), "") {
+		if v := field(&redacted, name); isSecret(name) && v.String() != "" {
+			v.SetString(RedactedValue)
+		}
+	}
+	return &redacted
+}
+
+// sets the settings overridden by environment variables; variables set to an empty
+// value are ignored
+func applyEnv(cfg *Configuration) error {
+	for _, name := range settingNames(reflect.TypeOf(*cfg), "") {
+		value := os.Getenv(EnvName(name))
+		if value == "" {
+			continue
+		}
+		if err := setValue(field(cfg, name), value); err != nil {
+			return fmt.Errorf("invalid value of %s: %s", EnvName(name), err)
+		}
+	}
+	return nil
+}
+
+// replaces references like ${VAR} in the string settings with the value of the environment
+// variable; ${APP_HOME} is the application location. It fails if a variable is not set.
+func expandVars(cfg *Configuration, appPath string) (err error) {
+	for _, name := range settingNames(reflect.TypeOf(*cfg), "") {
+		v := field(cfg, name)
+		if v.Kind() != reflect.String {
+			continue
+		}
+
+		expanded := varPattern.ReplaceAllStringFunc(v.String(), func(ref string) string {
+			key := ref[2 : len(ref)-1]
+			if key == "APP_HOME" {
+				return appPath
+			}
+
+			value, found := os.LookupEnv(key)
+			if !found && err == nil {
+				err = fmt.Errorf("%s refers to environment variable %s, which is not set", name, key)
+			}
+			return value
+		})
+		if err != nil {
+			return
+		}
+		v.SetString(expanded)
+	}
+	return
+}
+
+// sets v from its text representation
+func setValue(v reflect.Value, value string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("'%s' is not an integer", value)
+		}
+		v.SetInt(int64(n))
+
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("'%s' is not true or false", value)
+		}
+		v.SetBool(b)
+
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("'%s' is not a number", value)
+		}
+		v.SetFloat(f)
+
+	default:
+		return fmt.Errorf("settings of type %s can't be set", v.Kind())
+	}
+	return nil
+}
+
+// returns the YAML paths of the settings of t; fields with no YAML name are skipped
+func settingNames(t reflect.Type, prefix string) (names []string) {
+	for i := 0; i < t.NumField(); i++ {
+		tag := yamlName(t.Field(i))
+		if tag == "" || tag == "-" {
+			continue
+		}
+
+		if t.Field(i).Type.Kind() == reflect.Struct {
+			names = append(names, settingNames(t.Field(i).Type, prefix+tag+".")...)
+			continue
+		}
+		names = append(names, prefix+tag)
+	}
+	return
+}
+
+// returns the field of cfg with the indicated YAML path
+func field(cfg *Configuration, name string) reflect.Value {
+	v := reflect.ValueOf(cfg).Elem()
+	for _, tag := range strings.Split(name, ".") {
+		for i := 0; i < v.NumField(); i++ {
+			if yamlName(v.Type().Field(i)) == tag {
+				v = v.Field(i)
+				break
+			}
+		}
+	}
+	return v
+}
+
+// returns true if the setting with the indicated YAML path is tagged as secret
+func isSecret(name string) bool {
+	t := reflect.TypeOf(Configuration{})
+	var f reflect.StructField
+	for _, tag := range strings.Split(name, ".") {
+		for i := 0; i < t.NumField(); i++ {
+			if yamlName(t.Field(i)) == tag {
+				f = t.Field(i)
+				t = f.Type
+				break
+			}
+		}
+	}
+	return f.Tag.Get("secret") == "true"
+}
+
+func yamlName(f reflect.StructField) string {
+	return strings.Split(f.Tag.Get("yaml"), ",")[0]
+}
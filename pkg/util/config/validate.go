@@ -0,0 +1,167 @@
+package config
+
+import (
+	"fmt"
+	"go-blog/pkg/util/log"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ValidationError lists the settings with invalid values
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+// settings that can't be negative; 0 disables most of them
+var nonNegative = []string{
+	"server.read_timeout",
+	"server.write_timeout",
+	"server.request_timeout",
+	"server.shutdown_timeout",
+	"server.min_free_space",
+	"server.maintenance_retry_after",
+	"server.idle_timeout",
+	"server.max_header_bytes",
+	"database.busy_timeout",
+	"database.max_open_conns",
+	"database.max_idle_conns",
+	"database.conn_max_lifetime",
+	"backup.interval",
+	"backup.retention",
+	"log.max_size",
+	"log.max_age",
+	"log.max_backups",
+}
+
+// Validate checks the values of the settings, and returns a *ValidationError with every
+// problem found, if any
+func (cfg *Configuration) Validate() error {
+	var problems []string
+	check := func(ok bool, name, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, name+": "+fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(validAddress(cfg.Server.Port), "server.port", "'%s' is not a valid address; a port like :8080, or an address like 127.0.0.1:8080, is expected", cfg.Server.Port)
+	for _, name := range nonNegative {
+		check(field(cfg, name).Int() >= 0, name, "must not be negative")
+	}
+	check(cfg.Server.TLSCertFile != "" || cfg.Server.TLSKeyFile == "", "server.tls_cert_file", "must be set along with server.tls_key_file")
+	check(cfg.Server.TLSKeyFile != "" || cfg.Server.TLSCertFile == "", "server.tls_key_file", "must be set along with server.tls_cert_file")
+	check(cfg.Server.KeepAlive || !cfg.Server.HTTP2, "server.http2", "needs server.keep_alive, as HTTP/2 connections are kept alive")
+	check(cfg.Server.Socket == "" || !cfg.Server.SocketActivation, "server.socket", "can't be used along with server.socket_activation")
+
+	switch cfg.Database.Driver {
+	case DriverSQLite:
+		check(oneOf(cfg.Database.JournalMode, "DELETE", "TRUNCATE", "PERSIST", "MEMORY", "WAL", "OFF"), "database.journal_mode", "'%s' is not a SQLite journal mode", cfg.Database.JournalMode)
+		check(oneOf(cfg.Database.Synchronous, "OFF", "NORMAL", "FULL", "EXTRA"), "database.synchronous", "'%s' is not a SQLite synchronous mode", cfg.Database.Synchronous)
+	case DriverPostgres, DriverMySQL:
+		check(cfg.Database.DSN != "", "database.dsn", "must be set for the %s driver", cfg.Database.Driver)
+	default:
+		check(false, "database.driver", "'%s' is not supported; use %s, %s or %s", cfg.Database.Driver, DriverSQLite, DriverPostgres, DriverMySQL)
+	}
+
+	check(cfg.Template.CheckCycle > 0, "template.check_cycle", "must be greater than 0")
+	_, errLoc := time.LoadLocation(strings.TrimSpace(cfg.Template.Timezone))
+	check(errLoc == nil, "template.timezone", "'%s' is not a known timezone", cfg.Template.Timezone)
+
+	check(oneOf(cfg.Tracing.Exporter, "none", "stdout", "otlp"), "tracing.exporter", "'%s' is not supported; use none, stdout or otlp", cfg.Tracing.Exporter)
+	check(cfg.Tracing.SampleRatio > 0 && cfg.Tracing.SampleRatio <= 1, "tracing.sample_ratio", "must be greater than 0, and not greater than 1")
+	if cfg.Tracing.Exporter == "otlp" {
+		u, errURL := url.Parse(cfg.Tracing.Endpoint)
+		check(errURL == nil && u.Host != "", "tracing.endpoint", "'%s' is not a URL like http://localhost:4318", cfg.Tracing.Endpoint)
+	}
+
+	check(contains(log.Levels, cfg.Log.Level), "log.level", "'%s' is not a level; use %s", cfg.Log.Level, strings.Join(log.Levels, ", "))
+	check(oneOf(cfg.Log.Format, log.FormatJSON, log.FormatConsole), "log.format", "'%s' is not supported; use %s or %s", cfg.Log.Format, log.FormatJSON, log.FormatConsole)
+	check(oneOf(cfg.Log.Output, log.OutputStdout, log.OutputFile), "log.output", "'%s' is not supported; use %s or %s", cfg.Log.Output, log.OutputStdout, log.OutputFile)
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// CheckPaths checks that the service can write in the folder of the SQLite database and
+// in the backups folder, which is created if needed, and, if templates is set, in the
+// templates folders; it returns a *ValidationError with every problem found, if any
+func (cfg *Configuration) CheckPaths(templates bool) error {
+	var problems []string
+	check := func(name, dir string, create bool) {
+		if err := writableDir(dir, create); err != nil {
+			problems = append(problems, name+": "+err.Error())
+		}
+	}
+
+	if cfg.Database.Driver == DriverSQLite {
+		check("database.filename", filepath.Dir(cfg.Database.Filename), false)
+	}
+	check("backup.location", cfg.Backup.Location, true)
+	if templates {
+		check("template.base_location", cfg.Template.Base, false)
+		check("template.processed_ok", cfg.Template.ProcessedOK, false)
+		check("template.processed_error", cfg.Template.ProcessedError, false)
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// checks that files can be created in dir; if create is set and dir doesn't exist, the
+// closest folder that exists is checked instead, as dir is created when needed
+func writableDir(dir string, create bool) error {
+	info, err := os.Stat(dir)
+	if os.IsNotExist(err) && create {
+		if parent := filepath.Dir(dir); parent != dir {
+			return writableDir(parent, true)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("folder %s can't be used: %s", dir, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a folder", dir)
+	}
+
+	f, err := ioutil.TempFile(dir, ".config-")
+	if err != nil {
+		return fmt.Errorf("folder %s is not writable", dir)
+	}
+	f.Close()
+	os.Remove(f.Name())
+
+	return nil
+}
+
+// returns true if addr is a port, like :8080, or a host and port, like 127.0.0.1:8080
+func validAddress(addr string) bool {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	n, err := strconv.Atoi(port)
+	return err == nil && n > 0 && n <= 65535
+}
+
+// returns true if value is one of values, with no regard to case
+func oneOf(value string, values ...string) bool {
+	for i := range values {
+		if strings.EqualFold(value, values[i]) {
+			return true
+		}
+	}
+	return false
+}
@@ -13,6 +13,10 @@ const (
 	CodeBadRequest          = "bad_request"
 	CodeInvalidPage         = "invalid_page"
 	CodeInvalidPageSize     = "invalid_page_size"
+	CodeInvalidTimezone     = "invalid_timezone"
+	CodeRequestTimeout      = "request_timeout"
+	CodeNotImplemented      = "not_implemented"
+	CodeMaintenance         = "maintenance"
 )
 
 var (
@@ -22,6 +26,10 @@ var (
 		CodeBadRequest:          "one or more parameters are missing or wrong",
 		CodeInvalidPage:         "invalid page value",
 		CodeInvalidPageSize:     "invalid page size value",
+		CodeInvalidTimezone:     "invalid timezone value",
+		CodeRequestTimeout:      "request took too long to complete",
+		CodeNotImplemented:      "operation is not supported by this server",
+		CodeMaintenance:         "service is under maintenance; only reads are available",
 	}
 )
 
@@ -0,0 +1,78 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// returned on platforms where free space can't be read
+var errFreeSpaceUnsupported = errors.New("free space is not supported on this platform")
+
+// Database checks the connection to db, with a ping and a trivial query
+func Database(db *sql.DB) Check {
+	return func(ctx context.Context) (details map[string]interface{}, err error) {
+
+		if err = db.PingContext(ctx); err != nil {
+			return
+		}
+
+		var n int
+		if err = db.QueryRowContext(ctx, "SELECT 1").Scan(&n); err != nil {
+			return
+		}
+
+		stats := db.Stats()
+		details = map[string]interface{}{
+			"open_connections": stats.OpenConnections,
+			"in_use":           stats.InUse,
+		}
+		return
+	}
+}
+
+// Writable checks that files can be created in every folder in dirs, by creating and
+// removing a temporary file
+func Writable(dirs ...string) Check {
+	return func(ctx context.Context) (details map[string]interface{}, err error) {
+		for _, dir := range dirs {
+			f, errCreate := ioutil.TempFile(dir, ".health-")
+			if errCreate != nil {
+				return nil, fmt.Errorf("folder %s is not writable: %s", dir, errCreate)
+			}
+			f.Close()
+			os.Remove(f.Name())
+		}
+		return
+	}
+}
+
+// DiskSpace checks that there are at least minFree bytes available in the file system
+// of every path in paths; the available space of each one of them is included in details.
+// Paths are reported as unknown on platforms where free space can't be read.
+func DiskSpace(minFree uint64, paths ...string) Check {
+	return func(ctx context.Context) (details map[string]interface{}, err error) {
+
+		details = make(map[string]interface{}, len(paths))
+		for _, path := range paths {
+			free, errFree := freeSpace(path)
+			if errFree == errFreeSpaceUnsupported {
+				details[path] = "unknown"
+				continue
+			}
+			if errFree != nil {
+				return details, fmt.Errorf("error reading free space of %s: %s", path, errFree)
+			}
+
+			details[path] = free
+			if free < minFree && err == nil {
+				err = fmt.Errorf("%s has %d bytes available; at least %d are required", path, free, minFree)
+			}
+		}
+
+		return
+	}
+}
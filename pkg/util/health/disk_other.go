@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package health
+
+// free space can't be read on this platform
+func freeSpace(path string) (uint64, error) {
+	return 0, errFreeSpaceUnsupported
+}
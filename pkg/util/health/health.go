@@ -0,0 +1,165 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Component and service statuses
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// DefaultTimeout is the time each check may take before the component is reported as
+// down; it's shorter than the default probe timeout of Kubernetes
+const DefaultTimeout = 800 * time.Millisecond
+
+// Check checks a component the service depends on; it returns an error if the component
+// is not ready. Details, if any, are included in the report.
+type Check func(ctx context.Context) (details map[string]interface{}, err error)
+
+// ComponentStatus is the outcome of checking a component
+type ComponentStatus struct {
+	Status    string                 `json:"status"`
+	LatencyMS float64                `json:"latency_ms"`
+	Error     string                 `json:"error,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+}
+
+// Report is the status of the service and its components
+type Report struct {
+	Status     string                     `json:"status"`
+	Service    string                     `json:"service"`
+	Version    string                     `json:"version,omitempty"`
+	Server     string                     `json:"server,omitempty"`
+	Components map[string]ComponentStatus `json:"components,omitempty"`
+}
+
+// Health runs the checks of the liveness and readiness probes
+type Health struct {
+	service string
+	version string
+	timeout time.Duration
+	checks  map[string]Check
+}
+
+// New creates the probes of service; each check may take up to timeout
+func New(service, version string, timeout time.Duration) *Health {
+	return &Health{
+		service: service,
+		version: version,
+		timeout: timeout,
+		checks:  make(map[string]Check),
+	}
+}
+
+// Add adds the check of a component to the readiness probe
+func (h *Health) Add(component string, check Check) {
+	h.checks[component] = check
+}
+
+// Register registers the probes in e: /health/live reports the process is able to serve
+// requests, while /health/ready checks every component. Both of them return 200 when
+// the service is up, and 503 otherwise.
+func (h *Health) Register(e *echo.Echo) {
+	e.GET("/health/live", h.liveHandler)
+	e.GET("/health/ready", h.readyHandler)
+}
+
+func (h *Health) liveHandler(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.newReport(StatusUp))
+}
+
+func (h *Health) readyHandler(c echo.Context) error {
+	report := h.Ready(c.Request().Context())
+
+	code := http.StatusOK
+	if report.Status != StatusUp {
+		code = http.StatusServiceUnavailable
+	}
+
+	return c.JSON(code, report)
+}
+
+// Ready runs every check concurrently, and returns the status of each component; the
+// service is up if every component is up
+func (h *Health) Ready(ctx context.Context) Report {
+
+	report := h.newReport(StatusUp)
+	report.Components = make(map[string]ComponentStatus, len(h.checks))
+
+	mu := sync.Mutex{}
+	wg := sync.WaitGroup{}
+
+	for name, check := range h.checks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+
+			status := h.run(ctx, check)
+
+			mu.Lock()
+			report.Components[name] = status
+			if status.Status != StatusUp {
+				report.Status = StatusDown
+			}
+			mu.Unlock()
+		}(name, check)
+	}
+
+	wg.Wait()
+	return report
+}
+
+// runs a check with the check timeout; checks that don't end in time are reported as down
+func (h *Health) run(ctx context.Context, check Check) (status ComponentStatus) {
+
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	type result struct {
+		details map[string]interface{}
+		err     error
+	}
+
+	done := make(chan result, 1)
+	start := time.Now()
+
+	go func() {
+		details, err := check(ctx)
+		done <- result{details, err}
+	}()
+
+	var res result
+	select {
+	case res = <-done:
+	case <-ctx.Done():
+		res.err = ctx.Err()
+	}
+
+	status.LatencyMS = float64(time.Since(start).Microseconds()) / 1000
+	status.Details = res.details
+	status.Status = StatusUp
+	if res.err != nil {
+		status.Status = StatusDown
+		status.Error = res.err.Error()
+	}
+
+	return
+}
+
+func (h *Health) newReport(status string) Report {
+	report := Report{Status: status, Service: h.service, Version: h.version}
+
+	if host, err := os.Hostname(); err == nil {
+		report.Server = host
+	}
+
+	return report
+}
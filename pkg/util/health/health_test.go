@@ -0,0 +1,117 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go-blog/pkg/util/health"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProbes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "health")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	up := func(ctx context.Context) (map[string]interface{}, error) { return nil, nil }
+	down := func(ctx context.Context) (map[string]interface{}, error) {
+		return nil, errors.New("connection refused")
+	}
+	slow := func(ctx context.Context) (map[string]interface{}, error) {
+		time.Sleep(time.Second)
+		return nil, nil
+	}
+
+	cases := []struct {
+		name       string
+		path       string
+		checks     map[string]health.Check
+		wantCode   int
+		wantStatus map[string]string
+	}{
+		{
+			name:     "Live",
+			path:     "/health/live",
+			checks:   map[string]health.Check{"database": down},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:       "Ready",
+			path:       "/health/ready",
+			checks:     map[string]health.Check{"database": up, "folders": health.Writable(dir), "disk": health.DiskSpace(1, dir)},
+			wantCode:   http.StatusOK,
+			wantStatus: map[string]string{"database": health.StatusUp, "folders": health.StatusUp, "disk": health.StatusUp},
+		},
+		{
+			name:       "Component down",
+			path:       "/health/ready",
+			checks:     map[string]health.Check{"database": down, "folders": health.Writable(dir)},
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: map[string]string{"database": health.StatusDown, "folders": health.StatusUp},
+		},
+		{
+			name:       "Check timeout",
+			path:       "/health/ready",
+			checks:     map[string]health.Check{"database": slow},
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: map[string]string{"database": health.StatusDown},
+		},
+		{
+			name:       "Folder not writable",
+			path:       "/health/ready",
+			checks:     map[string]health.Check{"folders": health.Writable(dir, filepath.Join(dir, "missing"))},
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: map[string]string{"folders": health.StatusDown},
+		},
+		{
+			name:       "Low disk space",
+			path:       "/health/ready",
+			checks:     map[string]health.Check{"disk": health.DiskSpace(math.MaxUint64, dir)},
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: map[string]string{"disk": health.StatusDown},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			h := health.New("blog", "1.0.0", 50*time.Millisecond)
+			for name, check := range tt.checks {
+				h.Add(name, check)
+			}
+
+			e := echo.New()
+			h.Register(e)
+
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			assert.Equal(t, tt.wantCode, rec.Code)
+
+			report := health.Report{}
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+			assert.Equal(t, "blog", report.Service)
+
+			status := map[string]string{}
+			for name, c := range report.Components {
+				status[name] = c.Status
+				if c.Status == health.StatusDown {
+					assert.NotEmpty(t, c.Error)
+				}
+			}
+			if tt.wantStatus == nil {
+				assert.Empty(t, status)
+			} else {
+				assert.Equal(t, tt.wantStatus, status)
+			}
+		})
+	}
+}
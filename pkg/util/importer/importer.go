@@ -0,0 +1,223 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"go-blog/pkg/util/model"
+	"go-blog/pkg/util/slug"
+	"go-blog/pkg/util/template"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Supported sources
+const (
+	SourceWordPress = "wordpress"
+	SourceHugo      = "hugo"
+	SourceJekyll    = "jekyll"
+	SourceRSS       = "rss"
+)
+
+// Import statuses
+const (
+	StatusImported  = "imported"
+	StatusDuplicate = "duplicate"
+	StatusSkipped   = "skipped"
+	StatusInvalid   = "invalid"
+	StatusFailed    = "failed"
+)
+
+// Entry is a post read from a source
+type Entry struct {
+	Post   model.Post
+	Source string // file or item the post was read from
+	Skip   string // reason why the post must not be imported, like being a draft
+}
+
+// Reader reads the posts of a source from path, which may be a file or a folder
+type Reader func(path string) ([]Entry, error)
+
+// Readers contains the readers of the supported sources
+var Readers = map[string]Reader{
+	SourceWordPress: ReadWordPress,
+	SourceHugo:      ReadHugo,
+	SourceJekyll:    ReadJekyll,
+	SourceRSS:       ReadRSS,
+}
+
+// Sources returns the names of the supported sources, sorted
+func Sources() (names []string) {
+	for name := range Readers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// Store holds the functions used to check and save imported posts; PreviewPost must work
+// like CreatePost, with no changes saved
+type Store interface {
+	CreatePost(ctx context.Context, post *model.Post) error
+	PreviewPost(ctx context.Context, post *model.Post) error
+	Titles(ctx context.Context) ([]string, error)
+}
+
+// Result is the outcome of importing an entry
+type Result struct {
+	Source  string `json:"source"`
+	Title   string `json:"title"`
+	Slug    string `json:"slug"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// Report contains the outcome of an import
+type Report struct {
+	DryRun  bool           `json:"dry_run"`
+	Results []Result       `json:"results"`
+	Counts  map[string]int `json:"counts"`
+}
+
+func (r *Report) add(res Result) {
+	r.Results = append(r.Results, res)
+	r.Counts[res.Status]++
+}
+
+// Options configures an import
+type Options struct {
+	DryRun        bool                // report the outcome without saving anything
+	DefaultAuthor string              // author of posts with no author in the source
+	Validator     *template.Validator // rules applied to posts, the same ones applied to templates
+}
+
+// Import validates the entries and saves them as new posts, one transaction per post.
+// Posts with the same title as an existing post, or as a previous entry, are reported as
+// duplicates and skipped; titles are compared with no regard to case, spacing or trailing
+// punctuation, but symbols within them are kept, so C++ Tips and C# Tips are different.
+// In dry run mode, posts are saved in transactions that are rolled back, so the report
+// shows what would be imported.
+func Import(ctx context.Context, store Store, entries []Entry, opts Options) (report Report, err error) {
+
+	report = Report{DryRun: opts.DryRun, Results: []Result{}, Counts: map[string]int{}}
+
+	titles, err := store.Titles(ctx)
+	if err != nil {
+		return
+	}
+
+	seen := make(map[string]bool, len(titles))
+	for _, title := range titles {
+		seen[normalizeTitle(title)] = true
+	}
+
+	for i := range entries {
+		post := entries[i].Post
+		if post.Author == "" {
+			post.Author = opts.DefaultAuthor
+		}
+
+		res := Result{Source: entries[i].Source, Title: post.Title, Slug: slug.Make(post.Title)}
+
+		if entries[i].Skip != "" {
+			res.Status, res.Message = StatusSkipped, entries[i].Skip
+			report.add(res)
+			continue
+		}
+
+		if seen[normalizeTitle(post.Title)] {
+			res.Status, res.Message = StatusDuplicate, "a post with the same title already exists"
+			report.add(res)
+			continue
+		}
+
+		// posts are checked with the template rules, rendering them as templates
+		validated, diags, errValidate := opts.Validator.Validate("", template.FormatTemplate(&post))
+		if errValidate != nil {
+			res.Status, res.Message = StatusInvalid, errValidate.Error()
+			report.add(res)
+			continue
+		}
+		if len(diags) > 0 {
+			res.Message = diags.String()
+		}
+
+		// dates not set in the source are set to the import date, as templates do
+		post.DateCreated, post.DateUpdated = validated.DateCreated, validated.DateUpdated
+		if post.DateCreated.IsZero() {
+			post.DateCreated = time.Now().UTC()
+		}
+		if post.DateUpdated.IsZero() {
+			post.DateUpdated = post.DateCreated
+		}
+
+		save := store.CreatePost
+		if opts.DryRun {
+			save = store.PreviewPost
+		}
+		if errSave := save(ctx, &post); errSave != nil {
+			res.Status, res.Message = StatusFailed, errSave.Error()
+			report.add(res)
+
+			// a canceled import must not go on
+			if ctx.Err() != nil {
+				return report, ctx.Err()
+			}
+			continue
+		}
+
+		seen[normalizeTitle(post.Title)] = true
+		res.Status = StatusImported
+		report.add(res)
+	}
+
+	return
+}
+
+// returns the title in lower case, with single spaces and no trailing punctuation, to
+// find duplicated posts
+func normalizeTitle(title string) string {
+	return strings.TrimRight(strings.Join(strings.Fields(strings.ToLower(title)), " "), ".!?:;, ")
+}
+
+// returns the HTML body of a post, as stored for templates
+func body(content string) string {
+	return "<body>\n" + strings.TrimSpace(content) + "\n</body>"
+}
+
+// returns the first non empty value
+func firstOf(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// date layouts found in sources, besides the template ones
+var sourceDateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05 -07:00",
+}
+
+// parses a date of a source; dates with no offset are taken as UTC
+func parseDate(value string) (t time.Time, err error) {
+	if value = strings.TrimSpace(value); value == "" {
+		return
+	}
+
+	if t, err = template.ParseDate(value, time.UTC); err == nil {
+		return
+	}
+
+	for _, layout := range sourceDateLayouts {
+		if t, err = time.Parse(layout, value); err == nil {
+			return t.UTC(), nil
+		}
+	}
+
+	return t, fmt.Errorf("unsupported date '%s'", value)
+}
@@ -0,0 +1,286 @@
+package importer_test
+
+import (
+	"context"
+	"errors"
+	"go-blog/pkg/util/importer"
+	"go-blog/pkg/util/model"
+	"go-blog/pkg/util/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const wxr = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:content="http://purl.org/rss/1.0/modules/content/" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:wp="http://wordpress.org/export/1.2/">
+<channel>
+	<item>
+		<title>Hello World</title>
+		<dc:creator>admin</dc:creator>
+		<content:encoded><![CDATA[First paragraph.
+
+Second paragraph.]]></content:encoded>
+		<wp:post_id>1</wp:post_id>
+		<wp:post_date>2020-04-15 10:30:00</wp:post_date>
+		<wp:post_date_gmt>2020-04-15 08:30:00</wp:post_date_gmt>
+		<wp:post_modified_gmt>2020-04-16 08:30:00</wp:post_modified_gmt>
+		<wp:status>publish</wp:status>
+		<wp:post_type>post</wp:post_type>
+		<category domain="category" nicename="news"><![CDATA[News]]></category>
+		<category domain="post_tag" nicename="go"><![CDATA[Go]]></category>
+	</item>
+	<item>
+		<title>Draft</title>
+		<wp:post_id>2</wp:post_id>
+		<wp:post_date>2020-04-17 10:30:00</wp:post_date>
+		<wp:post_date_gmt>0000-00-00 00:00:00</wp:post_date_gmt>
+		<wp:status>draft</wp:status>
+		<wp:post_type>post</wp:post_type>
+	</item>
+	<item>
+		<title>About</title>
+		<wp:post_id>3</wp:post_id>
+		<wp:post_type>page</wp:post_type>
+	</item>
+</channel>
+</rss>`
+
+const rss = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0">
+<channel>
+	<item>
+		<title>Feed Post</title>
+		<guid>https://example.com/feed-post</guid>
+		<pubDate>Wed, 15 Apr 2020 10:30:00 +0200</pubDate>
+		<author>jane@example.com (Jane Doe)</author>
+		<description>&lt;p&gt;Summary&lt;/p&gt;</description>
+		<category>News</category>
+	</item>
+</channel>
+</rss>`
+
+const hugoYAML = `---
+title: "YAML Post"
+date: 2020-04-15T10:30:00Z
+lastmod: 2020-04-16T10:30:00Z
+author: John Doe
+categories: [News]
+tags: [Go, Web]
+---
+
+Some **Markdown**.
+`
+
+const hugoTOML = `+++
+title = "TOML Post"
+date = "2020-04-15T10:30:00Z"
+tags = ["Rock, Pop", "Jazz"]
+draft = true
++++
+
+Content.
+`
+
+const jekyll = `---
+layout: post
+category: Notes
+tags: go web
+---
+
+- first
+- second
+`
+
+func TestReaders(t *testing.T) {
+	dir, err := ioutil.TempDir("", "importer")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"wordpress.xml":                             wxr,
+		"feed.xml":                                  rss,
+		"hugo/content/posts/yaml.md":                hugoYAML,
+		"hugo/content/posts/toml.md":                hugoTOML,
+		"hugo/content/posts/_index.md":              "---\ntitle: Posts\n---\n",
+		"jekyll/_posts/2020-04-15-jekyll-post.md":   jekyll,
+		"jekyll/_posts/2020-04-16-unpublished.html": "---\npublished: false\n---\n<p>hidden</p>\n",
+	}
+	for name, content := range files {
+		file := filepath.Join(dir, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(file), 0755))
+		assert.NoError(t, ioutil.WriteFile(file, []byte(content), 0644))
+	}
+
+	date := time.Date(2020, 4, 15, 8, 30, 0, 0, time.UTC)
+
+	cases := []struct {
+		name     string
+		read     importer.Reader
+		path     string
+		wantErr  bool
+		wantPost []model.Post
+		wantSkip []bool
+	}{
+		{
+			name: "WordPress",
+			read: importer.ReadWordPress,
+			path: filepath.Join(dir, "wordpress.xml"),
+			wantPost: []model.Post{
+				{Title: "Hello World", Author: "admin", Content: "<body>\n<p>First paragraph.</p>\n<p>Second paragraph.</p>\n</body>", DateCreated: date, DateUpdated: date.Add(24 * time.Hour), CategoryList: []model.Category{{Name: "News"}}, TagList: []model.Tag{{Name: "Go"}}},
+				{Title: "Draft", Content: "<body>\n\n</body>", DateCreated: date.Add(50 * time.Hour)},
+			},
+			wantSkip: []bool{false, true},
+		},
+		{
+			name: "RSS",
+			read: importer.ReadRSS,
+			path: filepath.Join(dir, "feed.xml"),
+			wantPost: []model.Post{
+				{Title: "Feed Post", Author: "Jane Doe", Content: "<body>\n<p>Summary</p>\n</body>", DateCreated: date, DateUpdated: date, CategoryList: []model.Category{{Name: "News"}}},
+			},
+			wantSkip: []bool{false},
+		},
+		{
+			name: "Hugo",
+			read: importer.ReadHugo,
+			path: filepath.Join(dir, "hugo"),
+			wantPost: []model.Post{
+				{Title: "TOML Post", Content: "<body>\n<p>Content.</p>\n</body>", DateCreated: date.Add(2 * time.Hour), TagList: []model.Tag{{Name: "Rock, Pop"}, {Name: "Jazz"}}},
+				{Title: "YAML Post", Author: "John Doe", Content: "<body>\n<p>Some <strong>Markdown</strong>.</p>\n</body>", DateCreated: date.Add(2 * time.Hour), DateUpdated: date.Add(26 * time.Hour), CategoryList: []model.Category{{Name: "News"}}, TagList: []model.Tag{{Name: "Go"}, {Name: "Web"}}},
+			},
+			wantSkip: []bool{true, false},
+		},
+		{
+			name: "Jekyll",
+			read: importer.ReadJekyll,
+			path: filepath.Join(dir, "jekyll"),
+			wantPost: []model.Post{
+				{Title: "Jekyll Post", Content: "<body>\n<ul>\n<li>first</li>\n<li>second</li>\n</ul>\n</body>", DateCreated: time.Date(2020, 4, 15, 0, 0, 0, 0, time.UTC), CategoryList: []model.Category{{Name: "Notes"}}, TagList: []model.Tag{{Name: "go"}, {Name: "web"}}},
+				{Title: "Unpublished", Content: "<body>\n<p>hidden</p>\n</body>", DateCreated: time.Date(2020, 4, 16, 0, 0, 0, 0, time.UTC)},
+			},
+			wantSkip: []bool{false, true},
+		},
+		{
+			name:    "Invalid file",
+			read:    importer.ReadRSS,
+			path:    filepath.Join(dir, "hugo/content/posts/yaml.md"),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := tt.read(tt.path)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+
+			if assert.Len(t, entries, len(tt.wantPost)) {
+				for i := range entries {
+					assert.Equal(t, tt.wantPost[i], entries[i].Post)
+					assert.Equal(t, tt.wantSkip[i], entries[i].Skip != "", entries[i].Skip)
+					assert.NotEmpty(t, entries[i].Source)
+				}
+			}
+		})
+	}
+}
+
+type store struct {
+	titles   []string
+	posts    []model.Post
+	previews int
+	err      error
+}
+
+func (s *store) Titles(ctx context.Context) ([]string, error) {
+	return s.titles, nil
+}
+
+func (s *store) CreatePost(ctx context.Context, post *model.Post) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.posts = append(s.posts, *post)
+	return nil
+}
+
+func (s *store) PreviewPost(ctx context.Context, post *model.Post) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.previews++
+	return nil
+}
+
+func TestImport(t *testing.T) {
+	date := time.Date(2020, 4, 15, 8, 30, 0, 0, time.UTC)
+	entries := []importer.Entry{
+		{Source: "1", Post: model.Post{Title: "New Post", Content: "<body><p>new</p></body>", DateCreated: date}},
+		{Source: "2", Post: model.Post{Title: "Existing Post", Author: "Jane Doe", Content: "<body><p>existing</p></body>"}},
+		{Source: "3", Post: model.Post{Title: "New post!", Author: "Jane Doe", Content: "<body><p>again</p></body>"}},
+		{Source: "4", Post: model.Post{Title: "Draft", Author: "Jane Doe", Content: "<body><p>draft</p></body>"}, Skip: "post is a draft"},
+		{Source: "5", Post: model.Post{Author: "Jane Doe", Content: "<body><p>untitled</p></body>"}},
+		{Source: "6", Post: model.Post{Title: "C++ Tips", Author: "Jane Doe", Content: "<body><p>c++</p></body>"}},
+		{Source: "7", Post: model.Post{Title: "C# Tips", Author: "Jane Doe", Content: "<body><p>c#</p></body>"}},
+	}
+
+	cases := []struct {
+		name         string
+		dryRun       bool
+		err          error
+		wantStatus   []string
+		wantSaved    int
+		wantPreviews int
+	}{
+		{
+			name:       "Import",
+			wantStatus: []string{importer.StatusImported, importer.StatusDuplicate, importer.StatusDuplicate, importer.StatusSkipped, importer.StatusInvalid, importer.StatusImported, importer.StatusImported},
+			wantSaved:  3,
+		},
+		{
+			name:         "Dry run",
+			dryRun:       true,
+			wantStatus:   []string{importer.StatusImported, importer.StatusDuplicate, importer.StatusDuplicate, importer.StatusSkipped, importer.StatusInvalid, importer.StatusImported, importer.StatusImported},
+			wantPreviews: 3,
+		},
+		{
+			name:       "Save error",
+			err:        errors.New("database is locked"),
+			wantStatus: []string{importer.StatusFailed, importer.StatusDuplicate, importer.StatusFailed, importer.StatusSkipped, importer.StatusInvalid, importer.StatusFailed, importer.StatusFailed},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &store{titles: []string{"Existing post"}, err: tt.err}
+
+			report, err := importer.Import(context.Background(), s, entries, importer.Options{
+				DryRun:        tt.dryRun,
+				DefaultAuthor: "John Doe",
+				Validator:     template.NewValidator(false, time.UTC),
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.dryRun, report.DryRun)
+
+			status := []string{}
+			for _, r := range report.Results {
+				status = append(status, r.Status)
+			}
+			assert.Equal(t, tt.wantStatus, status)
+			assert.Equal(t, tt.wantPreviews, s.previews)
+
+			if assert.Len(t, s.posts, tt.wantSaved) && tt.wantSaved > 0 {
+				assert.Equal(t, "John Doe", s.posts[0].Author)
+				assert.Equal(t, date, s.posts[0].DateCreated)
+				assert.Equal(t, date, s.posts[0].DateUpdated)
+			}
+		})
+	}
+}
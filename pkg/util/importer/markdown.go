@@ -0,0 +1,357 @@
+package importer
+
+import (
+	"bytes"
+	"fmt"
+	"go-blog/pkg/util/model"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/russross/blackfriday/v2"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// extensions of the files read from Hugo and Jekyll sites
+var markdownExtensions = map[string]bool{".md": true, ".markdown": true}
+
+// Jekyll posts are named after their date and slug, like 2020-04-15-my-first-post.md
+var jekyllFileName = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})-(.+)$`)
+
+// ReadHugo reads the Markdown posts of a Hugo site; path may be the site, its content
+// folder or a single file. Front matter may be written in YAML or TOML. Section index
+// pages are ignored, and drafts are skipped.
+func ReadHugo(path string) (entries []Entry, err error) {
+
+	if fi, errStat := os.Stat(filepath.Join(path, "content")); errStat == nil && fi.IsDir() {
+		path = filepath.Join(path, "content")
+	}
+
+	files, err := listFiles(path, func(name string) bool {
+		return markdownExtensions[filepath.Ext(name)] && !strings.HasPrefix(filepath.Base(name), "_index.")
+	})
+	if err != nil {
+		return
+	}
+
+	for _, file := range files {
+		fm, content, errRead := readMarkdown(file)
+		if errRead != nil {
+			entries = append(entries, Entry{Source: file, Skip: errRead.Error()})
+			continue
+		}
+
+		entry := markdownEntry(file, fm, content, false)
+		entry.Post.DateUpdated = dateValue(fm["lastmod"])
+		if fm.bool("draft") {
+			entry.Skip = "post is a draft"
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return
+}
+
+// ReadJekyll reads the posts of a Jekyll site; path may be the site, its _posts folder
+// or a single file. The date and title are taken from the file name when they are not
+// set in the front matter. Unpublished posts are skipped.
+func ReadJekyll(path string) (entries []Entry, err error) {
+
+	if fi, errStat := os.Stat(filepath.Join(path, "_posts")); errStat == nil && fi.IsDir() {
+		path = filepath.Join(path, "_posts")
+	}
+
+	files, err := listFiles(path, func(name string) bool {
+		return markdownExtensions[filepath.Ext(name)] || filepath.Ext(name) == ".html"
+	})
+	if err != nil {
+		return
+	}
+
+	for _, file := range files {
+		fm, content, errRead := readMarkdown(file)
+		if errRead != nil {
+			entries = append(entries, Entry{Source: file, Skip: errRead.Error()})
+			continue
+		}
+
+		// file names hold the post date and slug
+		name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		if m := jekyllFileName.FindStringSubmatch(name); m != nil {
+			if fm["date"] == nil {
+				fm["date"] = m[1]
+			}
+			if fm.string("title") == "" {
+				fm["title"] = strings.Title(strings.Replace(m[2], "-", " ", -1))
+			}
+		}
+
+		// HTML posts are not converted
+		entry := markdownEntry(file, fm, content, filepath.Ext(file) == ".html")
+		entry.Post.DateUpdated = dateValue(fm["last_modified_at"])
+		if published, found := fm["published"]; found && published == false {
+			entry.Skip = "post is not published"
+		}
+
+		// the singular form is accepted too
+		if len(entry.Post.CategoryList) == 0 {
+			for _, name := range fm.list("category") {
+				entry.Post.CategoryList = append(entry.Post.CategoryList, model.Category{Name: name})
+			}
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return
+}
+
+// builds the entry of a Markdown file, with the fields shared by Hugo and Jekyll;
+// content is converted to HTML, unless it's HTML already
+func markdownEntry(file string, fm frontMatter, content []byte, isHTML bool) (entry Entry) {
+
+	entry.Source = file
+
+	html := string(content)
+	if !isHTML {
+		html = string(blackfriday.Run(content))
+	}
+
+	entry.Post = model.Post{
+		Title:   fm.string("title"),
+		Author:  firstOf(fm.string("author"), firstOfList(fm.list("authors"))),
+		Content: body(html),
+	}
+
+	if date, found := fm["date"]; found {
+		if entry.Post.DateCreated = dateValue(date); entry.Post.DateCreated.IsZero() {
+			entry.Skip = fmt.Sprintf("unsupported date '%v'", date)
+		}
+	}
+
+	for _, name := range fm.list("categories") {
+		entry.Post.CategoryList = append(entry.Post.CategoryList, model.Category{Name: name})
+	}
+	for _, name := range fm.list("tags") {
+		entry.Post.TagList = append(entry.Post.TagList, model.Tag{Name: name})
+	}
+
+	return
+}
+
+// lists the files in path, or path itself if it's a file, that match the filter;
+// files are sorted by path
+func listFiles(path string, match func(name string) bool) (files []string, err error) {
+	err = filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && match(file) {
+			files = append(files, file)
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return
+}
+
+// frontMatter contains the metadata of a Markdown file
+type frontMatter map[string]interface{}
+
+// reads a Markdown file, splitting its front matter from the content
+func readMarkdown(file string) (fm frontMatter, content []byte, err error) {
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return
+	}
+	data = bytes.Replace(data, []byte("\r\n"), []byte("\n"), -1)
+
+	fm = frontMatter{}
+	for _, delim := range []string{"---", "+++"} {
+		if !bytes.HasPrefix(data, []byte(delim+"\n")) {
+			continue
+		}
+
+		end := bytes.Index(data[len(delim)+1:], []byte("\n"+delim))
+		if end < 0 {
+			return nil, nil, fmt.Errorf("front matter is not closed with '%s'", delim)
+		}
+
+		header := data[len(delim)+1 : len(delim)+1+end]
+		content = bytes.TrimLeft(data[len(delim)+1+end+len(delim)+1:], "\n")
+
+		if delim == "---" {
+			err = yaml.Unmarshal(header, &fm)
+		} else {
+			fm, err = parseTOML(string(header))
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("error reading front matter: %s", err)
+		}
+		return
+	}
+
+	return fm, data, nil
+}
+
+// returns the value as a string
+func (fm frontMatter) string(key string) string {
+	if v, found := fm[key]; found && v != nil {
+		return strings.TrimSpace(fmt.Sprint(v))
+	}
+	return ""
+}
+
+// returns the value as a boolean
+func (fm frontMatter) bool(key string) bool {
+	b, _ := fm[key].(bool)
+	return b
+}
+
+// returns the value as a list; strings are split by commas or, if there are none,
+// by spaces, as Jekyll does
+func (fm frontMatter) list(key string) (values []string) {
+	switch v := fm[key].(type) {
+	case []interface{}:
+		for i := range v {
+			if s := strings.TrimSpace(fmt.Sprint(v[i])); s != "" {
+				values = append(values, s)
+			}
+		}
+	case string:
+		sep := " "
+		if strings.Contains(v, ",") {
+			sep = ","
+		}
+		for _, s := range strings.Split(v, sep) {
+			if s = strings.TrimSpace(s); s != "" {
+				values = append(values, s)
+			}
+		}
+	}
+	return
+}
+
+// returns the value as a date; zero is returned if it's not a date
+func dateValue(v interface{}) time.Time {
+	switch d := v.(type) {
+	case time.Time:
+		return d.UTC()
+	case string:
+		t, _ := parseDate(d)
+		return t
+	}
+	return time.Time{}
+}
+
+func firstOfList(values []string) string {
+	if len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// parses the keys of a TOML front matter. Only the types used in front matter are
+// supported: strings, numbers, booleans, dates and arrays of them; tables are ignored.
+func parseTOML(data string) (fm frontMatter, err error) {
+
+	fm = frontMatter{}
+	inTable := false
+
+	for i, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			inTable = true
+			continue
+		}
+		if inTable {
+			continue
+		}
+
+		eq := strings.Index(line, "=")
+		if eq < 0 {
+			return nil, fmt.Errorf("line %d: expected key = value", i+1)
+		}
+
+		key := strings.Trim(strings.TrimSpace(line[:eq]), `"'`)
+		value := strings.TrimSpace(line[eq+1:])
+
+		if strings.HasPrefix(value, "[") {
+			if !strings.HasSuffix(value, "]") {
+				return nil, fmt.Errorf("line %d: arrays must be written in a single line", i+1)
+			}
+			list := []interface{}{}
+			for _, item := range splitTOMLArray(value[1 : len(value)-1]) {
+				list = append(list, tomlValue(item))
+			}
+			fm[key] = list
+			continue
+		}
+
+		fm[key] = tomlValue(value)
+	}
+
+	return
+}
+
+// splits the items of a TOML array, ignoring commas inside strings
+func splitTOMLArray(s string) (items []string) {
+	var quote rune
+	start := 0
+	for i, r := range s {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote == 0 && (r == '"' || r == '\''):
+			quote = r
+		case quote == 0 && r == ',':
+			items = append(items, s[start:i])
+			start = i + 1
+		}
+	}
+	if last := strings.TrimSpace(s[start:]); last != "" {
+		items = append(items, last)
+	}
+	return
+}
+
+// returns the value of a TOML scalar
+func tomlValue(s string) interface{} {
+	s = strings.TrimSpace(s)
+
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		if s[0] == '"' {
+			if unquoted, err := strconv.Unquote(s); err == nil {
+				return unquoted
+			}
+		}
+		return s[1 : len(s)-1]
+	}
+
+	// comments after values
+	if i := strings.Index(s, " #"); i >= 0 {
+		s = strings.TrimSpace(s[:i])
+	}
+
+	switch s {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if n, err := strconv.ParseFloat(s, 64); err == nil {
+		return n
+	}
+
+	return s
+}
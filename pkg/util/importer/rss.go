@@ -0,0 +1,81 @@
+package importer
+
+import (
+	"encoding/xml"
+	"fmt"
+	"go-blog/pkg/util/model"
+	"os"
+	"strings"
+)
+
+// RSS 2.0 feed
+type rssFeed struct {
+	Channel struct {
+		Items []rssItem `xml:"item"`
+	} `xml:"channel"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        string   `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Author      string   `xml:"author"`
+	Creator     string   `xml:"creator"`
+	Description string   `xml:"description"`
+	Content     string   `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	Categories  []string `xml:"category"`
+}
+
+// ReadRSS reads the posts of an RSS 2.0 feed; the full content is used if the feed
+// includes it, or the description otherwise. Item categories are imported as categories.
+func ReadRSS(path string) (entries []Entry, err error) {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	feed := rssFeed{}
+	if err = xml.NewDecoder(f).Decode(&feed); err != nil {
+		return nil, fmt.Errorf("error reading RSS feed: %s", err)
+	}
+
+	for _, item := range feed.Channel.Items {
+		entry := Entry{
+			Source: fmt.Sprintf("%s#%s", path, firstOf(item.GUID, item.Link, item.Title)),
+			Post: model.Post{
+				Title:   strings.TrimSpace(item.Title),
+				Author:  rssAuthor(firstOf(item.Creator, item.Author)),
+				Content: body(firstOf(item.Content, item.Description)),
+			},
+		}
+
+		date, errDate := parseDate(item.PubDate)
+		if errDate != nil {
+			entry.Skip = errDate.Error()
+		}
+		entry.Post.DateCreated = date
+		entry.Post.DateUpdated = entry.Post.DateCreated
+
+		for _, name := range item.Categories {
+			if name = strings.TrimSpace(name); name != "" {
+				entry.Post.CategoryList = append(entry.Post.CategoryList, model.Category{Name: name})
+			}
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return
+}
+
+// RSS authors are email addresses, optionally followed by the name in parentheses,
+// like `john@example.com (John Doe)`; the name is used if present
+func rssAuthor(author string) string {
+	if start, end := strings.Index(author, "("), strings.LastIndex(author, ")"); start >= 0 && end > start {
+		return strings.TrimSpace(author[start+1 : end])
+	}
+	return author
+}
@@ -0,0 +1,121 @@
+package importer
+
+import (
+	"encoding/xml"
+	"fmt"
+	"go-blog/pkg/util/model"
+	"os"
+	"strings"
+)
+
+// namespace of the content:encoded element, used by WordPress and RSS feeds
+const contentNamespace = "http://purl.org/rss/1.0/modules/content/"
+
+// WordPress eXtended RSS file; elements in the wp namespace are matched by name, as
+// the namespace changes with the WXR version
+type wxrFile struct {
+	Channel struct {
+		Items []wxrItem `xml:"item"`
+	} `xml:"channel"`
+}
+
+type wxrItem struct {
+	Title      string        `xml:"title"`
+	Link       string        `xml:"link"`
+	Creator    string        `xml:"creator"`
+	Content    string        `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	PostID     string        `xml:"post_id"`
+	PostName   string        `xml:"post_name"`
+	PostType   string        `xml:"post_type"`
+	Status     string        `xml:"status"`
+	DateGMT    string        `xml:"post_date_gmt"`
+	Date       string        `xml:"post_date"`
+	Modified   string        `xml:"post_modified_gmt"`
+	Categories []wxrCategory `xml:"category"`
+}
+
+type wxrCategory struct {
+	Domain   string `xml:"domain,attr"`
+	Nicename string `xml:"nicename,attr"`
+	Name     string `xml:",chardata"`
+}
+
+// ReadWordPress reads the posts of a WordPress export (WXR) file. Pages, attachments
+// and other item types are ignored; posts that are not published are skipped.
+func ReadWordPress(path string) (entries []Entry, err error) {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	wxr := wxrFile{}
+	if err = xml.NewDecoder(f).Decode(&wxr); err != nil {
+		return nil, fmt.Errorf("error reading WordPress export: %s", err)
+	}
+
+	for _, item := range wxr.Channel.Items {
+		if item.PostType != "" && item.PostType != "post" {
+			continue
+		}
+
+		entry := Entry{
+			Source: fmt.Sprintf("%s#%s", path, firstOf(item.PostID, item.PostName, item.Title)),
+			Post: model.Post{
+				Title:   strings.TrimSpace(item.Title),
+				Author:  strings.TrimSpace(item.Creator),
+				Content: body(wordPressContent(item.Content)),
+			},
+		}
+
+		if item.Status != "" && item.Status != "publish" {
+			entry.Skip = "post status is " + item.Status
+		}
+
+		// GMT dates are not set for drafts
+		dateCreated := item.DateGMT
+		if dateCreated == "" || strings.HasPrefix(dateCreated, "0000") {
+			dateCreated = item.Date
+		}
+		date, errDate := parseDate(dateCreated)
+		if errDate != nil {
+			entry.Skip = errDate.Error()
+		}
+		entry.Post.DateCreated = date
+		if !strings.HasPrefix(item.Modified, "0000") {
+			entry.Post.DateUpdated, _ = parseDate(item.Modified)
+		}
+
+		for _, c := range item.Categories {
+			name := strings.TrimSpace(c.Name)
+			switch c.Domain {
+			case "category":
+				entry.Post.CategoryList = append(entry.Post.CategoryList, model.Category{Name: name})
+			case "post_tag":
+				entry.Post.TagList = append(entry.Post.TagList, model.Tag{Name: name})
+			}
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return
+}
+
+// WordPress stores paragraphs as blank lines, which are rendered as <p> tags by the
+// theme; content that is not already made of blocks is wrapped in paragraphs
+func wordPressContent(content string) string {
+	content = strings.TrimSpace(strings.Replace(content, "\r\n", "\n", -1))
+	if content == "" || strings.HasPrefix(content, "<!-- wp:") || strings.HasPrefix(content, "<p") {
+		return content
+	}
+
+	sb := strings.Builder{}
+	for _, p := range strings.Split(content, "\n\n") {
+		if p = strings.TrimSpace(p); p != "" {
+			sb.WriteString("<p>" + strings.Replace(p, "\n", "<br/>\n", -1) + "</p>\n")
+		}
+	}
+	return sb.String()
+}
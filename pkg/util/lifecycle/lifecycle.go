@@ -0,0 +1,205 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"go-blog/pkg/util/log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DefaultTimeout is the time the service is given to stop, if none is set
+const DefaultTimeout = 20 * time.Second
+
+// ErrTimeout is returned by Wait when the service didn't stop in time
+var ErrTimeout = errors.New("service didn't stop in time")
+
+// Manager starts and stops the components of the service. Components run with a root
+// context, which is canceled on SIGINT or SIGTERM, or when a component fails; once they
+// return, the stop hooks are run, in reverse order, like deferred calls.
+type Manager struct {
+	logger  *log.Log
+	timeout time.Duration
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	signals chan os.Signal
+
+	running sync.WaitGroup
+
+	mu     sync.Mutex
+	err    error
+	hooks  []hook
+	hangup func()
+}
+
+// a function run when the service stops, after every component returned
+type hook struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+// New creates a manager; timeout is the time components and stop hooks are given
+// to end, once the service starts stopping. Signals are handled by the manager from
+// this point on.
+func New(logger *log.Log, timeout time.Duration) *Manager {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{
+		logger:  logger,
+		timeout: timeout,
+		ctx:     ctx,
+		cancel:  cancel,
+		signals: signals,
+	}
+}
+
+// Context returns the root context, which is done once the service starts stopping
+func (m *Manager) Context() context.Context {
+	return m.ctx
+}
+
+// Go runs a component in a new goroutine; run must return once ctx is done. If it
+// returns an error before that, the service is stopped.
+func (m *Manager) Go(name string, run func(ctx context.Context) error) {
+	m.running.Add(1)
+	go func() {
+		defer m.running.Done()
+
+		if err := run(m.ctx); err != nil && m.ctx.Err() == nil {
+			m.logger.Error("component failed; stopping the service", err, map[string]interface{}{"component": name})
+			m.fail(err)
+		}
+	}()
+}
+
+// OnStop registers a function to be run when the service stops, after every component
+// returned; ctx is done when the stop timeout expires
+func (m *Manager) OnStop(name string, stop func(ctx context.Context) error) {
+	m.mu.Lock()
+	m.hooks = append(m.hooks, hook{name: name, stop: stop})
+	m.mu.Unlock()
+}
+
+// OnHangup sets the function run each time SIGHUP is received, like reloading the
+// configuration; if it's not set, SIGHUP is ignored
+func (m *Manager) OnHangup(fn func()) {
+	m.mu.Lock()
+	m.hangup = fn
+	m.mu.Unlock()
+}
+
+// Shutdown makes the service stop, as if a SIGTERM was received
+func (m *Manager) Shutdown() {
+	m.cancel()
+}
+
+// Wait blocks until the service is stopped, by a signal, by Shutdown or by a failed
+// component, and then stops it. SIGHUP runs the function set with OnHangup, so the
+// service never stops by the loss of its terminal; a second SIGINT or SIGTERM exits
+// right away. It returns the error of the component that failed, if any, or ErrTimeout
+// if the service didn't stop in time.
+func (m *Manager) Wait() (err error) {
+
+	defer signal.Stop(m.signals)
+
+	m.waitForStop()
+
+	// every component and hook shares the same deadline
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	// signals received while stopping force the exit
+	go func() {
+		for sig := range m.signals {
+			if sig == syscall.SIGHUP {
+				continue
+			}
+			m.logger.Warn("signal received while stopping; exiting now", map[string]interface{}{"signal": sig.String()})
+			os.Exit(1)
+		}
+	}()
+
+	stopped := make(chan struct{})
+	go func() {
+		m.running.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		m.logger.Warn("components didn't stop in time", map[string]interface{}{"timeout": m.timeout.String()})
+		err = ErrTimeout
+	}
+
+	m.mu.Lock()
+	hooks := m.hooks
+	m.mu.Unlock()
+
+	// hooks are run even if the deadline expired, so resources like the database
+	// are always released
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if errStop := hooks[i].stop(ctx); errStop != nil {
+			m.logger.Error("error stopping "+hooks[i].name, errStop, nil)
+			if errors.Is(errStop, context.DeadlineExceeded) && err == nil {
+				err = ErrTimeout
+			}
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		err = m.err
+	}
+	return
+}
+
+// blocks until a signal asks the service to stop, or the root context is canceled
+func (m *Manager) waitForStop() {
+	for {
+		select {
+		case sig := <-m.signals:
+			if sig == syscall.SIGHUP {
+				m.mu.Lock()
+				hangup := m.hangup
+				m.mu.Unlock()
+
+				if hangup == nil {
+					m.logger.Info("SIGHUP received; ignored", nil)
+				} else {
+					hangup()
+				}
+				continue
+			}
+			m.logger.Info("stopping service", map[string]interface{}{"signal": sig.String(), "timeout": m.timeout.String()})
+			m.cancel()
+			return
+
+		case <-m.ctx.Done():
+			m.logger.Info("stopping service", map[string]interface{}{"timeout": m.timeout.String()})
+			return
+		}
+	}
+}
+
+// records the error of the first component that failed, and stops the service
+func (m *Manager) fail(err error) {
+	m.mu.Lock()
+	if m.err == nil {
+		m.err = err
+	}
+	m.mu.Unlock()
+
+	m.cancel()
+}
@@ -0,0 +1,109 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package lifecycle_test
+
+import (
+	"context"
+	"errors"
+	"go-blog/pkg/util/lifecycle"
+	"go-blog/pkg/util/log"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWait(t *testing.T) {
+	errFailed := errors.New("failed")
+
+	cases := []struct {
+		name     string
+		stop     func(m *lifecycle.Manager)
+		run      func(ctx context.Context) error
+		wantErr  error
+		wantRuns []string
+	}{
+		{
+			name: "SIGTERM",
+			stop: func(m *lifecycle.Manager) {
+				syscall.Kill(syscall.Getpid(), syscall.SIGHUP) // ignored
+				syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
+			},
+			run:      func(ctx context.Context) error { <-ctx.Done(); return nil },
+			wantRuns: []string{"component", "second hook", "first hook"},
+		},
+		{
+			name:     "Shutdown",
+			stop:     func(m *lifecycle.Manager) { m.Shutdown() },
+			run:      func(ctx context.Context) error { <-ctx.Done(); return nil },
+			wantRuns: []string{"component", "second hook", "first hook"},
+		},
+		{
+			name:     "Failed component",
+			stop:     func(m *lifecycle.Manager) {},
+			run:      func(ctx context.Context) error { return errFailed },
+			wantErr:  errFailed,
+			wantRuns: []string{"component", "second hook", "first hook"},
+		},
+		{
+			name: "Timeout",
+			stop: func(m *lifecycle.Manager) { m.Shutdown() },
+			run: func(ctx context.Context) error {
+				<-ctx.Done()
+				time.Sleep(time.Second)
+				return nil
+			},
+			wantErr:  lifecycle.ErrTimeout,
+			wantRuns: []string{"second hook", "first hook"},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			m := lifecycle.New(log.New(), 100*time.Millisecond)
+
+			runs := make(chan string, 3)
+			m.Go("component", func(ctx context.Context) error {
+				err := tt.run(ctx)
+				runs <- "component"
+				return err
+			})
+			m.OnStop("first hook", func(ctx context.Context) error { runs <- "first hook"; return nil })
+			m.OnStop("second hook", func(ctx context.Context) error { runs <- "second hook"; return nil })
+
+			tt.stop(m)
+			err := m.Wait()
+
+			assert.Equal(t, tt.wantErr, err)
+			assert.Error(t, m.Context().Err())
+
+			var got []string
+			for len(runs) > 0 {
+				got = append(got, <-runs)
+			}
+			assert.Equal(t, tt.wantRuns, got)
+		})
+	}
+}
+
+func TestOnHangup(t *testing.T) {
+	m := lifecycle.New(log.New(), 100*time.Millisecond)
+
+	hangups := make(chan struct{}, 1)
+	m.OnHangup(func() { hangups <- struct{}{} })
+
+	m.Go("component", func(ctx context.Context) error {
+		syscall.Kill(syscall.Getpid(), syscall.SIGHUP)
+		select {
+		case <-hangups:
+		case <-time.After(time.Second):
+			t.Error("SIGHUP was not handled")
+		}
+		m.Shutdown()
+		return nil
+	})
+
+	assert.NoError(t, m.Wait())
+}
@@ -1,12 +1,111 @@
 package log
 
 import (
+	"context"
+	"fmt"
+	"go-blog/pkg/util/tracing"
+	"io"
 	"os"
+	"sync"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/rs/zerolog"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
+// Supported formats
+const (
+	FormatJSON    = "json"
+	FormatConsole = "console"
+)
+
+// Supported outputs
+const (
+	OutputStdout = "stdout"
+	OutputFile   = "file"
+)
+
+// Config represents the logging settings
+type Config struct {
+	Level      string
+	Format     string // json or console
+	Output     string // stdout or file
+	File       string // file written when the output is file
+	MaxSize    int    // megabytes the file may take before being rotated
+	MaxAge     int    // days rotated files are kept; 0 keeps them
+	MaxBackups int    // number of rotated files kept; 0 keeps all of them
+}
+
+// writer used by the loggers created by New
+var (
+	outputMu sync.RWMutex
+	output   io.Writer = os.Stdout
+)
+
+// Setup sets the level of every logger, and the format and output of the loggers created
+// by New from now on; the returned function closes the log file, if any
+func Setup(cfg Config) (close func() error, err error) {
+
+	close = func() error { return nil }
+
+	if err = SetLevel(cfg.Level); err != nil {
+		return
+	}
+
+	var w io.Writer
+	switch cfg.Output {
+	case "", OutputStdout:
+		w = os.Stdout
+
+	case OutputFile:
+		file := &lumberjack.Logger{
+			Filename:   cfg.File,
+			MaxSize:    cfg.MaxSize,
+			MaxAge:     cfg.MaxAge,
+			MaxBackups: cfg.MaxBackups,
+		}
+		// the file is opened on the first write, so errors are found right away
+		if _, err = file.Write(nil); err != nil {
+			return close, fmt.Errorf("error opening log file: %s", err)
+		}
+		w, close = file, file.Close
+
+	default:
+		return close, fmt.Errorf("unknown log output '%s'", cfg.Output)
+	}
+
+	switch cfg.Format {
+	case "", FormatJSON:
+	case FormatConsole:
+		w = zerolog.ConsoleWriter{Out: w, TimeFormat: time.RFC3339, NoColor: cfg.Output == OutputFile}
+	default:
+		return close, fmt.Errorf("unknown log format '%s'", cfg.Format)
+	}
+
+	outputMu.Lock()
+	output = w
+	outputMu.Unlock()
+
+	return
+}
+
+// Levels lists the levels that can be set with SetLevel, from the most verbose
+var Levels = []string{"debug", "info", "warn", "error"}
+
+// SetLevel sets the lowest level of the messages logged by every logger; it can be
+// changed at any time
+func SetLevel(level string) error {
+	for _, name := range Levels {
+		if name == level {
+			l, _ := zerolog.ParseLevel(level)
+			zerolog.SetGlobalLevel(l)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown log level '%s'", level)
+}
+
 // Log represents zerolog logger
 type Log struct {
 	logger *zerolog.Logger
@@ -14,12 +113,32 @@ type Log struct {
 
 // New instantiates new zero logger
 func New() *Log {
-	z := zerolog.New(os.Stdout)
+	outputMu.RLock()
+	z := zerolog.New(output).With().Timestamp().Logger()
+	outputMu.RUnlock()
+
 	return &Log{
 		logger: &z,
 	}
 }
 
+// WithContext returns a logger that adds the IDs of the request and the template ingestion
+// held by ctx, if any, to every message, so the messages of each one can be told apart
+func (z *Log) WithContext(ctx context.Context) *Log {
+	c := z.logger.With()
+	if id := tracing.RequestID(ctx); id != "" {
+		c = c.Str("request_id", id)
+	}
+	if id := tracing.IngestionID(ctx); id != "" {
+		c = c.Str("ingestion_id", id)
+	}
+	l := c.Logger()
+
+	return &Log{
+		logger: &l,
+	}
+}
+
 // Log with HTTP context logs using zerolog
 func (z *Log) Log(ctx echo.Context, source, msg string, err error, params map[string]interface{}) {
 
@@ -0,0 +1,88 @@
+package maintenance
+
+import (
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultFile is the maintenance file used if none is configured
+const DefaultFile = "/etc/app-mode/maintenance"
+
+// Mode controls the maintenance mode of the service. Maintenance is on while the
+// maintenance file exists, so it's shared by every process using the same file, and it
+// can be turned on and off by creating and removing the file by hand too.
+type Mode struct {
+	file atomic.Value // string
+}
+
+// Status describes the maintenance mode
+type Status struct {
+	Enabled bool       `json:"enabled"`
+	Since   *time.Time `json:"since,omitempty"`
+}
+
+// New creates the maintenance mode controlled by file
+func New(file string) *Mode {
+	m := &Mode{}
+	m.SetFile(file)
+	return m
+}
+
+// SetFile changes the file that controls the maintenance mode; maintenance is not turned
+// on or off, so the new file must be created or removed to change it
+func (m *Mode) SetFile(file string) {
+	m.file.Store(file)
+}
+
+// File returns the file that controls the maintenance mode
+func (m *Mode) File() string {
+	return m.file.Load().(string)
+}
+
+// Enabled returns true if maintenance is on
+func (m *Mode) Enabled() bool {
+	_, err := os.Stat(m.File())
+	return err == nil
+}
+
+// Status returns whether maintenance is on and, if it is, since when
+func (m *Mode) Status() (status Status) {
+	if fi, err := os.Stat(m.File()); err == nil {
+		since := fi.ModTime().UTC()
+		status.Enabled, status.Since = true, &since
+	}
+	return
+}
+
+// Enable turns maintenance on, creating the maintenance file and its folder if they
+// don't exist; it has no effect if maintenance is already on
+func (m *Mode) Enable() (err error) {
+	if m.Enabled() {
+		return
+	}
+
+	file := m.File()
+	if err = os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return
+	}
+
+	f, err := os.Create(file)
+	if err != nil {
+		return
+	}
+	_, err = f.WriteString(time.Now().UTC().Format(time.RFC3339) + "\n")
+	if errClose := f.Close(); err == nil {
+		err = errClose
+	}
+	return
+}
+
+// Disable turns maintenance off, removing the maintenance file
+func (m *Mode) Disable() (err error) {
+	if err = os.Remove(m.File()); os.IsNotExist(err) {
+		err = nil
+	}
+	return
+}
@@ -0,0 +1,33 @@
+package maintenance_test
+
+import (
+	"go-blog/pkg/util/maintenance"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "maintenance")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	mode := maintenance.New(filepath.Join(dir, "first"))
+	assert.NoError(t, mode.Enable())
+	assert.True(t, mode.Enabled())
+
+	// maintenance follows the new file, which doesn't exist
+	second := filepath.Join(dir, "second")
+	mode.SetFile(second)
+	assert.Equal(t, second, mode.File())
+	assert.False(t, mode.Enabled())
+
+	assert.NoError(t, mode.Enable())
+	assert.FileExists(t, second)
+	assert.NoError(t, mode.Disable())
+	assert.False(t, mode.Enabled())
+	assert.FileExists(t, filepath.Join(dir, "first"))
+}
@@ -0,0 +1,127 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Namespace is the prefix of the metrics of the service
+const Namespace = "blog"
+
+// HTTP metrics, by method, route and status code
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of HTTP requests served.",
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Time taken to serve HTTP requests.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+)
+
+// Ingestion metrics
+var (
+	TemplatesProcessed = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "templates_processed_total",
+		Help:      "Number of templates saved as posts.",
+	})
+
+	TemplatesFailed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "templates_failed_total",
+		Help:      "Number of templates rejected, by the stage where they failed.",
+	}, []string{"stage"})
+
+	TemplateProcessingDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "template_processing_duration_seconds",
+		Help:      "Time taken to process a template, from reading it to moving it.",
+		Buckets:   prometheus.DefBuckets,
+	})
+
+	WatcherCycleDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "watcher_cycle_duration_seconds",
+		Help:      "Time taken to look for templates and send them to be processed.",
+		Buckets:   prometheus.DefBuckets,
+	})
+
+	WatcherQueueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "watcher_queue_depth",
+		Help:      "Number of templates sent to be processed whose processing hasn't ended.",
+	})
+)
+
+// DBQueryDuration holds the latency of database operations, by operation
+var DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: Namespace,
+	Name:      "db_query_duration_seconds",
+	Help:      "Time taken by database operations.",
+	Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+}, []string{"operation"})
+
+// ObserveQuery records the duration of a database operation started at start; it's
+// meant to be deferred, like `defer metrics.ObserveQuery("get_posts", time.Now())`
+func ObserveQuery(operation string, start time.Time) {
+	DBQueryDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+// Totals holds the number of rows of the main tables
+type Totals struct {
+	Posts      int
+	Categories int
+	Tags       int
+}
+
+// totalsTimeout is the time totals may take to be counted on each scrape
+const totalsTimeout = 2 * time.Second
+
+// collects the totals when metrics are scraped, so they are always up to date
+type totalsCollector struct {
+	count      func(ctx context.Context) (Totals, error)
+	posts      *prometheus.Desc
+	categories *prometheus.Desc
+	tags       *prometheus.Desc
+}
+
+// RegisterTotals registers the gauges with the number of posts, categories and tags,
+// which are counted with count each time metrics are scraped
+func RegisterTotals(count func(ctx context.Context) (Totals, error)) error {
+	return prometheus.Register(&totalsCollector{
+		count:      count,
+		posts:      prometheus.NewDesc(Namespace+"_posts", "Number of posts.", nil, nil),
+		categories: prometheus.NewDesc(Namespace+"_categories", "Number of categories.", nil, nil),
+		tags:       prometheus.NewDesc(Namespace+"_tags", "Number of tags.", nil, nil),
+	})
+}
+
+func (c *totalsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.posts
+	ch <- c.categories
+	ch <- c.tags
+}
+
+func (c *totalsCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), totalsTimeout)
+	defer cancel()
+
+	totals, err := c.count(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.posts, err)
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.posts, prometheus.GaugeValue, float64(totals.Posts))
+	ch <- prometheus.MustNewConstMetric(c.categories, prometheus.GaugeValue, float64(totals.Categories))
+	ch <- prometheus.MustNewConstMetric(c.tags, prometheus.GaugeValue, float64(totals.Tags))
+}
@@ -0,0 +1,210 @@
+package migration
+
+import (
+	"fmt"
+	"go-blog/pkg/util/log"
+	"sort"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Migration is a versioned change of the database structure. Up applies the
+// change and Down reverts it; both of them run inside a transaction, but MySQL
+// commits structure changes right away, so they must be safe to run again.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// Record represents an applied migration, as stored in the schema_migrations table
+type Record struct {
+	Version   int       `gorm:"column:version;primary_key;AUTO_INCREMENT:false"`
+	Name      string    `gorm:"column:name;type:varchar(128);NOT NULL"`
+	AppliedAt time.Time `gorm:"column:applied_at;NOT NULL"`
+}
+
+// TableName returns the table name for the model
+func (Record) TableName() string {
+	return "schema_migrations"
+}
+
+// Status describes a known migration and whether it was applied or not
+type Status struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// New creates a migrator for the indicated migrations
+func New(ds *gorm.DB, logger *log.Log, migrations []Migration) *Migrator {
+	sorted := make([]Migration, len(migrations))
+	copy(sorted, migrations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+
+	return &Migrator{
+		ds:         ds,
+		logger:     logger,
+		migrations: sorted,
+	}
+}
+
+// Migrator applies and reverts migrations, keeping track of them in the
+// schema_migrations table
+type Migrator struct {
+	ds         *gorm.DB
+	logger     *log.Log
+	migrations []Migration
+}
+
+// Latest returns the version of the last known migration
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Version returns the version of the last migration applied to the database
+func (m *Migrator) Version() (version int, err error) {
+	applied, err := m.applied()
+	if err != nil {
+		return
+	}
+
+	for v := range applied {
+		if v > version {
+			version = v
+		}
+	}
+	return
+}
+
+// Up applies all pending migrations, in version order; returns the number of
+// migrations applied
+func (m *Migrator) Up() (count int, err error) {
+
+	applied, err := m.applied()
+	if err != nil {
+		return
+	}
+
+	for _, mig := range m.migrations {
+		if _, done := applied[mig.Version]; done {
+			continue
+		}
+
+		m.logger.Info("applying migration", map[string]interface{}{"version": mig.Version, "name": mig.Name})
+
+		err = m.inTransaction(func(tx *gorm.DB) error {
+			if errUp := mig.Up(tx); errUp != nil {
+				return errUp
+			}
+			return tx.Create(&Record{Version: mig.Version, Name: mig.Name, AppliedAt: time.Now().UTC()}).Error
+		})
+		if err != nil {
+			return count, fmt.Errorf("error applying migration %d (%s): %s", mig.Version, mig.Name, err)
+		}
+
+		count++
+	}
+
+	return
+}
+
+// Down reverts the last steps applied migrations, in reverse version order;
+// returns the number of migrations reverted
+func (m *Migrator) Down(steps int) (count int, err error) {
+
+	applied, err := m.applied()
+	if err != nil {
+		return
+	}
+
+	for i := len(m.migrations) - 1; i >= 0 && count < steps; i-- {
+		mig := m.migrations[i]
+		if _, done := applied[mig.Version]; !done {
+			continue
+		}
+
+		if mig.Down == nil {
+			return count, fmt.Errorf("migration %d (%s) can't be reverted", mig.Version, mig.Name)
+		}
+
+		m.logger.Info("reverting migration", map[string]interface{}{"version": mig.Version, "name": mig.Name})
+
+		err = m.inTransaction(func(tx *gorm.DB) error {
+			if errDown := mig.Down(tx); errDown != nil {
+				return errDown
+			}
+			return tx.Delete(&Record{Version: mig.Version}).Error
+		})
+		if err != nil {
+			return count, fmt.Errorf("error reverting migration %d (%s): %s", mig.Version, mig.Name, err)
+		}
+
+		count++
+	}
+
+	return
+}
+
+// Status returns the list of known migrations, and whether they were applied or not
+func (m *Migrator) Status() (status []Status, err error) {
+
+	applied, err := m.applied()
+	if err != nil {
+		return
+	}
+
+	for _, mig := range m.migrations {
+		s := Status{Version: mig.Version, Name: mig.Name}
+		if record, done := applied[mig.Version]; done {
+			s.Applied = true
+			s.AppliedAt = record.AppliedAt
+		}
+		status = append(status, s)
+	}
+
+	return
+}
+
+// returns the migrations applied to the database, by version; the
+// schema_migrations table is created if it doesn't exist
+func (m *Migrator) applied() (applied map[int]Record, err error) {
+
+	if err = m.ds.AutoMigrate(&Record{}).Error; err != nil {
+		return nil, fmt.Errorf("error creating migrations table: %s", err)
+	}
+
+	records := []Record{}
+	if err = m.ds.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("error loading applied migrations: %s", err)
+	}
+
+	applied = make(map[int]Record)
+	for i := range records {
+		applied[records[i].Version] = records[i]
+	}
+
+	return
+}
+
+// runs fn in a transaction, which is rolled back if fn fails
+func (m *Migrator) inTransaction(fn func(tx *gorm.DB) error) (err error) {
+
+	tx := m.ds.Begin()
+	if err = tx.Error; err != nil {
+		return
+	}
+
+	if err = fn(tx); err != nil {
+		tx.Rollback()
+		return
+	}
+
+	return tx.Commit().Error
+}
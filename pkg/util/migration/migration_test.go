@@ -0,0 +1,219 @@
+package migration_test
+
+import (
+	"errors"
+	"go-blog/pkg/util/log"
+	"go-blog/pkg/util/migration"
+	"strings"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrator(t *testing.T) {
+	ds, err := gorm.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	defer ds.Close()
+
+	// every connection gets its own in-memory database
+	ds.DB().SetMaxOpenConns(1)
+
+	migrations := []migration.Migration{
+		{
+			Version: 2,
+			Name:    "add_column",
+			Up:      func(tx *gorm.DB) error { return tx.Exec("ALTER TABLE item ADD COLUMN name TEXT").Error },
+			Down:    func(tx *gorm.DB) error { return errors.New("not supported") },
+		},
+		{
+			Version: 1,
+			Name:    "create_table",
+			Up:      func(tx *gorm.DB) error { return tx.Exec("CREATE TABLE item (id INTEGER)").Error },
+			Down:    func(tx *gorm.DB) error { return tx.Exec("DROP TABLE item").Error },
+		},
+	}
+
+	m := migration.New(ds, log.New(), migrations)
+	assert.Equal(t, 2, m.Latest())
+
+	count, err := m.Up()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	version, err := m.Version()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, version)
+
+	// nothing left to apply
+	count, err = m.Up()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	// failed steps are rolled back and not recorded
+	count, err = m.Down(1)
+	assert.Error(t, err)
+	assert.Equal(t, 0, count)
+
+	status, err := m.Status()
+	assert.NoError(t, err)
+	assert.Len(t, status, 2)
+	assert.True(t, status[0].Applied)
+	assert.True(t, status[1].Applied)
+}
+
+func TestNormalizeTaxonomy(t *testing.T) {
+	ds, err := gorm.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	defer ds.Close()
+
+	ds.DB().SetMaxOpenConns(1)
+
+	// a database created before migrations were versioned, with names per post
+	m := migration.New(ds, log.New(), migration.Migrations[:1])
+	_, err = m.Up()
+	assert.NoError(t, err)
+	assert.NoError(t, ds.Exec(`INSERT INTO post (date_created, date_updated, title, author, content, original_filename)
+		VALUES (CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, 'Languages', 'Jane Doe', '', 'languages.tpl')`).Error)
+	for _, name := range []string{"C++", "C#", "C", "c#", strings.Repeat("+", 100)} {
+		assert.NoError(t, ds.Exec(`INSERT INTO post_category (id_post, name) VALUES (1, ?)`, name).Error)
+	}
+
+	m = migration.New(ds, log.New(), migration.Migrations)
+	_, err = m.Up()
+	assert.NoError(t, err)
+
+	type category struct {
+		Name string
+		Slug string
+	}
+	categories := []category{}
+	assert.NoError(t, ds.Raw(`SELECT name, slug FROM category ORDER BY id_category`).Scan(&categories).Error)
+	assert.Equal(t, []category{
+		{Name: "C++", Slug: "c"},
+		{Name: "C#", Slug: "c-2"},
+		{Name: "C", Slug: "c-3"},
+		{Name: strings.Repeat("+", 100), Slug: strings.Repeat("2b", 64)},
+	}, categories)
+
+	count := 0
+	assert.NoError(t, ds.Table("post_category").Count(&count).Error)
+	assert.Equal(t, 4, count)
+}
+
+// MySQL commits structure changes right away, so a migration that fails may leave them
+// applied; applying it again must continue where it stopped
+func TestTaxonomyAppliedAgain(t *testing.T) {
+	cases := []struct {
+		name    string
+		down    bool
+		partial func(ds *gorm.DB) error
+	}{
+		{
+			name: "Table renamed",
+			partial: func(ds *gorm.DB) error {
+				return ds.Exec(`ALTER TABLE post_category RENAME TO post_category_old`).Error
+			},
+		},
+		{
+			name: "Entities partially created",
+			partial: func(ds *gorm.DB) error {
+				for _, stmt := range []string{
+					`ALTER TABLE post_category RENAME TO post_category_old`,
+					`CREATE TABLE category (
+						id_category INTEGER PRIMARY KEY AUTOINCREMENT,
+						name        VARCHAR (128) NOT NULL,
+						slug        VARCHAR (128) NOT NULL,
+						description VARCHAR (512) NOT NULL DEFAULT ''
+					)`,
+					`CREATE UNIQUE INDEX idx_category_slug ON category (slug)`,
+					`INSERT INTO category (name, slug) VALUES ('Go', 'go')`,
+				} {
+					if err := ds.Exec(stmt).Error; err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			name:    "Applied but not recorded",
+			partial: migration.Migrations[1].Up,
+		},
+		{
+			name: "Join table dropped",
+			down: true,
+			partial: func(ds *gorm.DB) error {
+				for _, stmt := range []string{
+					`CREATE TABLE post_category_old (id_post INTEGER NOT NULL, name VARCHAR (128) NOT NULL)`,
+					`INSERT INTO post_category_old (id_post, name)
+						SELECT l.id_post, e.name FROM post_category l INNER JOIN category e ON e.id_category = l.id_category`,
+					`DROP TABLE post_category`,
+				} {
+					if err := ds.Exec(stmt).Error; err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			name:    "Reverted but not recorded",
+			down:    true,
+			partial: migration.Migrations[1].Down,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			ds, err := gorm.Open("sqlite3", ":memory:")
+			assert.NoError(t, err)
+			defer ds.Close()
+
+			ds.DB().SetMaxOpenConns(1)
+
+			m := migration.New(ds, log.New(), migration.Migrations[:1])
+			_, err = m.Up()
+			assert.NoError(t, err)
+			assert.NoError(t, ds.Exec(`INSERT INTO post (date_created, date_updated, title, author, content, original_filename)
+				VALUES (CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, 'Go', 'Jane Doe', '', 'go.tpl')`).Error)
+			assert.NoError(t, ds.Exec(`INSERT INTO post_category (id_post, name) VALUES (1, 'Go'), (1, 'go')`).Error)
+			assert.NoError(t, ds.Exec(`INSERT INTO post_tag (id_post, name) VALUES (1, 'web')`).Error)
+
+			m = migration.New(ds, log.New(), migration.Migrations)
+			if tt.down {
+				_, err = m.Up()
+				assert.NoError(t, err)
+			}
+
+			assert.NoError(t, tt.partial(ds))
+
+			names := []string{}
+			if tt.down {
+				count, errDown := m.Down(1)
+				assert.NoError(t, errDown)
+				assert.Equal(t, 1, count)
+				assert.False(t, ds.HasTable("category"))
+				assert.NoError(t, ds.Table("post_category").Order("name").Pluck("name", &names).Error)
+				assert.Equal(t, []string{"Go"}, names)
+
+				tags := []string{}
+				assert.NoError(t, ds.Table("post_tag").Pluck("name", &tags).Error)
+				assert.Equal(t, []string{"web"}, tags)
+			} else {
+				count, errUp := m.Up()
+				assert.NoError(t, errUp)
+				assert.Equal(t, 1, count)
+				assert.False(t, ds.HasTable("post_category_old"))
+				assert.NoError(t, ds.Raw(`SELECT c.slug FROM post_category pc
+					INNER JOIN category c ON c.id_category = pc.id_category`).Pluck("slug", &names).Error)
+				assert.Equal(t, []string{"go"}, names)
+
+				count = 0
+				assert.NoError(t, ds.Table("post_tag").Count(&count).Error)
+				assert.Equal(t, 1, count)
+			}
+		})
+	}
+}
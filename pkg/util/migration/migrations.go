@@ -0,0 +1,256 @@
+package migration
+
+import (
+	"go-blog/pkg/util/config"
+	"go-blog/pkg/util/slug"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Migrations contains the changes applied to the database structure over time.
+// New migrations must be appended with the next version number; applied
+// migrations must never be changed. Statements must work on every supported
+// database, or be built with the dialect helpers below. MySQL commits each
+// structure change right away, so a migration that fails there is not rolled
+// back: migrations must check the structure, to continue where they stopped
+// when they're applied again.
+var Migrations = []Migration{
+	{
+		// tables may already exist in databases created before migrations were versioned
+		Version: 1,
+		Name:    "create_post_tables",
+		Up: func(tx *gorm.DB) error {
+			return execAll(tx,
+				`CREATE TABLE IF NOT EXISTS post (
+					id_post           `+autoIncrementKey(tx)+`,
+					date_created      `+dateTimeType(tx)+` NOT NULL,
+					date_updated      `+dateTimeType(tx)+` NOT NULL,
+					title             VARCHAR (128) NOT NULL,
+					author            VARCHAR (128) NOT NULL,
+					content           TEXT          NOT NULL,
+					original_filename VARCHAR (128) NOT NULL
+				)`,
+				`CREATE TABLE IF NOT EXISTS post_category (
+					id_post INTEGER       NOT NULL,
+					name    VARCHAR (128) NOT NULL
+				)`,
+				`CREATE TABLE IF NOT EXISTS post_tag (
+					id_post INTEGER       NOT NULL,
+					name    VARCHAR (128) NOT NULL
+				)`)
+		},
+		Down: func(tx *gorm.DB) error {
+			return execAll(tx,
+				`DROP TABLE IF EXISTS post_tag`,
+				`DROP TABLE IF EXISTS post_category`,
+				`DROP TABLE IF EXISTS post`)
+		},
+	},
+	{
+		// categories and tags become entities, linked to posts through join tables
+		Version: 2,
+		Name:    "normalize_taxonomy",
+		Up: func(tx *gorm.DB) error {
+			for _, t := range taxonomies {
+				if err := normalizeTaxonomy(tx, t); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, t := range taxonomies {
+				if err := denormalizeTaxonomy(tx, t); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+}
+
+// taxonomy describes the tables of categories or tags, to build their statements
+type taxonomy struct {
+	entity string // entity table
+	key    string // primary key of the entity table
+	link   string // join table between posts and entities
+}
+
+var taxonomies = []taxonomy{
+	{entity: "category", key: "id_category", link: "post_category"},
+	{entity: "tag", key: "id_tag", link: "post_tag"},
+}
+
+// replaces {entity}, {key} and {link} placeholders in the statement
+func (t taxonomy) sql(stmt string) string {
+	return strings.NewReplacer("{entity}", t.entity, "{key}", t.key, "{link}", t.link).Replace(stmt)
+}
+
+// creates the entity and join tables, and converts the names stored per post
+// into entities; rows of posts that don't exist anymore are discarded. The old
+// table is dropped at the end, so while it exists the conversion starts over,
+// keeping the tables already created.
+func normalizeTaxonomy(tx *gorm.DB, t taxonomy) (err error) {
+
+	if !hasTable(tx, t.sql(`{link}_old`)) {
+		if hasTable(tx, t.entity) {
+			return // already converted
+		}
+		if err = tx.Exec(t.sql(`ALTER TABLE {link} RENAME TO {link}_old`)).Error; err != nil {
+			return
+		}
+	}
+
+	err = execAll(tx,
+		t.sql(`CREATE TABLE IF NOT EXISTS {entity} (
+			{key}       `+autoIncrementKey(tx)+`,
+			name        VARCHAR (128) NOT NULL,
+			slug        VARCHAR (128) NOT NULL,
+			description VARCHAR (512) NOT NULL DEFAULT ''
+		)`),
+		createIndex(tx, t.entity, t.sql(`idx_{entity}_slug`), t.sql(`CREATE UNIQUE INDEX idx_{entity}_slug ON {entity} (slug)`)),
+		createIndex(tx, t.entity, t.sql(`idx_{entity}_name`), t.sql(`CREATE INDEX idx_{entity}_name ON {entity} (name)`)),
+		t.sql(`CREATE TABLE IF NOT EXISTS {link} (
+			id_post INTEGER NOT NULL,
+			{key}   INTEGER NOT NULL,
+			PRIMARY KEY (id_post, {key}),
+			FOREIGN KEY (id_post) REFERENCES post (id_post) ON DELETE CASCADE,
+			FOREIGN KEY ({key}) REFERENCES {entity} ({key}) ON DELETE CASCADE
+		)`),
+		createIndex(tx, t.link, t.sql(`idx_{link}_{key}`), t.sql(`CREATE INDEX idx_{link}_{key} ON {link} ({key})`)),
+		t.sql(`DELETE FROM {link}`),
+		t.sql(`DELETE FROM {entity}`))
+	if err != nil {
+		return
+	}
+
+	type row struct {
+		IDPost int
+		Name   string
+	}
+
+	rows := []row{}
+	if err = tx.Raw(t.sql(`SELECT o.id_post, o.name FROM {link}_old o
+		INNER JOIN post p ON p.id_post = o.id_post ORDER BY o.id_post`)).Scan(&rows).Error; err != nil {
+		return
+	}
+
+	// names that only differ in case are merged into the same entity; different names
+	// get different slugs, even if they only differ in symbols, like C++ and C#
+	ids := make(map[string]int)
+	slugs := make(map[string]bool)
+	linked := make(map[[2]int]bool)
+
+	for _, r := range rows {
+		name := strings.TrimSpace(r.Name)
+		if name == "" {
+			continue
+		}
+
+		key := strings.ToLower(name)
+		id, found := ids[key]
+		if !found {
+			s, _ := slug.Unique(name, func(s string) (bool, error) { return slugs[s], nil })
+			slugs[s] = true
+
+			if err = tx.Exec(t.sql(`INSERT INTO {entity} (name, slug) VALUES (?, ?)`), name, s).Error; err != nil {
+				return
+			}
+			if err = tx.Raw(t.sql(`SELECT {key} FROM {entity} WHERE slug = ?`), s).Row().Scan(&id); err != nil {
+				return
+			}
+			ids[key] = id
+		}
+
+		if linked[[2]int{r.IDPost, id}] {
+			continue
+		}
+		linked[[2]int{r.IDPost, id}] = true
+
+		if err = tx.Exec(t.sql(`INSERT INTO {link} (id_post, {key}) VALUES (?, ?)`), r.IDPost, id).Error; err != nil {
+			return
+		}
+	}
+
+	return tx.Exec(t.sql(`DROP TABLE {link}_old`)).Error
+}
+
+// restores the names stored per post from the entity and join tables; each
+// step is skipped if a previous attempt already completed it
+func denormalizeTaxonomy(tx *gorm.DB, t taxonomy) (err error) {
+
+	if hasTable(tx, t.link) && hasTable(tx, t.entity) {
+		err = execAll(tx,
+			t.sql(`CREATE TABLE IF NOT EXISTS {link}_old (
+				id_post INTEGER       NOT NULL,
+				name    VARCHAR (128) NOT NULL
+			)`),
+			t.sql(`DELETE FROM {link}_old`),
+			t.sql(`INSERT INTO {link}_old (id_post, name)
+				SELECT l.id_post, e.name FROM {link} l INNER JOIN {entity} e ON e.{key} = l.{key}`),
+			t.sql(`DROP TABLE {link}`))
+		if err != nil {
+			return
+		}
+	}
+
+	if hasTable(tx, t.entity) {
+		if err = tx.Exec(t.sql(`DROP TABLE {entity}`)).Error; err != nil {
+			return
+		}
+	}
+
+	if hasTable(tx, t.sql(`{link}_old`)) {
+		err = tx.Exec(t.sql(`ALTER TABLE {link}_old RENAME TO {link}`)).Error
+	}
+	return
+}
+
+// runs the statements in order, stopping on the first error; empty statements
+// are skipped
+func execAll(tx *gorm.DB, statements ...string) error {
+	for _, stmt := range statements {
+		if stmt == "" {
+			continue
+		}
+		if err := tx.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// returns true if the table exists
+func hasTable(tx *gorm.DB, table string) bool {
+	return tx.Dialect().HasTable(table)
+}
+
+// returns stmt, which creates the index of table, or an empty statement if the
+// index already exists
+func createIndex(tx *gorm.DB, table, index, stmt string) string {
+	if hasTable(tx, table) && tx.Dialect().HasIndex(table, index) {
+		return ""
+	}
+	return stmt
+}
+
+// returns the definition of an auto incremented integer primary key
+func autoIncrementKey(tx *gorm.DB) string {
+	switch tx.Dialect().GetName() {
+	case config.DriverPostgres:
+		return "SERIAL PRIMARY KEY"
+	case config.DriverMySQL:
+		return "INTEGER PRIMARY KEY AUTO_INCREMENT"
+	default:
+		return "INTEGER PRIMARY KEY AUTOINCREMENT"
+	}
+}
+
+// returns the column type used for dates
+func dateTimeType(tx *gorm.DB) string {
+	if tx.Dialect().GetName() == config.DriverPostgres {
+		return "TIMESTAMP WITH TIME ZONE"
+	}
+	return "DATETIME"
+}
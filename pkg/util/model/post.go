@@ -16,15 +16,17 @@ const (
 
 // Post represents a blog post
 type Post struct {
-	ID               int       `gorm:"column:id_post;primary_key;AUTO_INCREMENT" json:"id_post"`
-	DateCreated      time.Time `gorm:"column:date_created;NOT NULL" json:"date_created"`
-	DateUpdated      time.Time `gorm:"column:date_updated;NOT NULL" json:"date_updated"`
-	Title            string    `gorm:"column:title;NOT NULL;type:varchar(128);NOT NULL" json:"title"`
-	Author           string    `gorm:"column:author;NOT NULL;type:varchar(128);NOT NULL" json:"author"`
-	Content          string    `gorm:"column:content;NOT NULL;type:text;NOT NULL" json:"content"`
-	Categories       string    `gorm:"-" json:"categories"`
-	Tags             string    `gorm:"-" json:"tags"`
-	OriginalFileName string    `gorm:"column:original_filename;type:varchar(128);NOT NULL" json:"-"`
+	ID               int        `gorm:"column:id_post;primary_key;AUTO_INCREMENT" json:"id_post"`
+	DateCreated      time.Time  `gorm:"column:date_created;NOT NULL" json:"date_created"`
+	DateUpdated      time.Time  `gorm:"column:date_updated;NOT NULL" json:"date_updated"`
+	Title            string     `gorm:"column:title;NOT NULL;type:varchar(128);NOT NULL" json:"title"`
+	Author           string     `gorm:"column:author;NOT NULL;type:varchar(128);NOT NULL" json:"author"`
+	Content          string     `gorm:"column:content;NOT NULL;type:text;NOT NULL" json:"content"`
+	Categories       string     `gorm:"-" json:"categories"`
+	Tags             string     `gorm:"-" json:"tags"`
+	CategoryList     []Category `gorm:"-" json:"-"`
+	TagList          []Tag      `gorm:"-" json:"-"`
+	OriginalFileName string     `gorm:"column:original_filename;type:varchar(128);NOT NULL" json:"-"`
 }
 
 // TableName returns the table name for the model
@@ -32,10 +34,23 @@ func (Post) TableName() string {
 	return "post"
 }
 
-// PostCategory represents a post category
+// Category represents a category that can be assigned to posts
+type Category struct {
+	ID          int    `gorm:"column:id_category;primary_key;AUTO_INCREMENT" json:"-"`
+	Name        string `gorm:"column:name;type:varchar(128);NOT NULL" json:"name"`
+	Slug        string `gorm:"column:slug;type:varchar(128);NOT NULL;unique_index" json:"slug"`
+	Description string `gorm:"column:description;type:varchar(512);NOT NULL" json:"description,omitempty"`
+}
+
+// TableName returns the table name for the model
+func (Category) TableName() string {
+	return "category"
+}
+
+// PostCategory links a post with one of its categories
 type PostCategory struct {
-	IDPost int    `gorm:"column:id_post;NOT NULL;type:integer" json:"id_post"`
-	Name   string `gorm:"column:name;NOT NULL;type:varchar(128);NOT NULL" json:"name"`
+	IDPost     int `gorm:"column:id_post;primary_key;AUTO_INCREMENT:false" json:"id_post"`
+	IDCategory int `gorm:"column:id_category;primary_key;AUTO_INCREMENT:false" json:"id_category"`
 }
 
 // TableName returns the table name for the model
@@ -43,10 +58,23 @@ func (PostCategory) TableName() string {
 	return "post_category"
 }
 
-// PostTag represents a post tag
+// Tag represents a tag that can be assigned to posts
+type Tag struct {
+	ID          int    `gorm:"column:id_tag;primary_key;AUTO_INCREMENT" json:"-"`
+	Name        string `gorm:"column:name;type:varchar(128);NOT NULL" json:"name"`
+	Slug        string `gorm:"column:slug;type:varchar(128);NOT NULL;unique_index" json:"slug"`
+	Description string `gorm:"column:description;type:varchar(512);NOT NULL" json:"description,omitempty"`
+}
+
+// TableName returns the table name for the model
+func (Tag) TableName() string {
+	return "tag"
+}
+
+// PostTag links a post with one of its tags
 type PostTag struct {
-	IDPost int    `gorm:"column:id_post;NOT NULL;type:integer" json:"id_post"`
-	Name   string `gorm:"column:name;NOT NULL;type:varchar(128);NOT NULL" json:"name"`
+	IDPost int `gorm:"column:id_post;primary_key;AUTO_INCREMENT:false" json:"id_post"`
+	IDTag  int `gorm:"column:id_tag;primary_key;AUTO_INCREMENT:false" json:"id_tag"`
 }
 
 // TableName returns the table name for the model
@@ -0,0 +1,27 @@
+package server
+
+import (
+	"crypto/subtle"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// AdminAuth returns a middleware that only lets through requests sending token as a
+// bearer token in the Authorization header. If token is empty, admin endpoints are
+// disabled, and every request gets a 404 response.
+func AdminAuth(token string) echo.MiddlewareFunc {
+	if token == "" {
+		return func(next echo.HandlerFunc) echo.HandlerFunc {
+			return func(c echo.Context) error {
+				return echo.ErrNotFound
+			}
+		}
+	}
+
+	return middleware.KeyAuthWithConfig(middleware.KeyAuthConfig{
+		Validator: func(key string, c echo.Context) (bool, error) {
+			return subtle.ConstantTimeCompare([]byte(key), []byte(token)) == 1, nil
+		},
+	})
+}
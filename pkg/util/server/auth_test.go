@@ -0,0 +1,42 @@
+package server_test
+
+import (
+	"go-blog/pkg/util/server"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminAuth(t *testing.T) {
+	cases := []struct {
+		name     string
+		token    string
+		header   string
+		wantCode int
+	}{
+		{name: "Disabled", token: "", header: "Bearer secret", wantCode: http.StatusNotFound},
+		{name: "Missing token", token: "secret", wantCode: http.StatusBadRequest},
+		{name: "Wrong token", token: "secret", header: "Bearer wrong", wantCode: http.StatusUnauthorized},
+		{name: "Valid token", token: "secret", header: "Bearer secret", wantCode: http.StatusOK},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			g := e.Group("/admin", server.AdminAuth(tt.token))
+			g.POST("/backup", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+
+			req := httptest.NewRequest(http.MethodPost, "/admin/backup", nil)
+			if tt.header != "" {
+				req.Header.Set(echo.HeaderAuthorization, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ErrorHandler returns a handler that writes errors like the default echo handler, but
+// adds the ID of the request to the JSON body, as request_id, so clients can refer to
+// the request, and its log messages, when reporting the error
+func ErrorHandler(e *echo.Echo) echo.HTTPErrorHandler {
+	return func(err error, c echo.Context) {
+
+		he, ok := err.(*echo.HTTPError)
+		if ok {
+			if herr, ok := he.Internal.(*echo.HTTPError); ok {
+				he = herr
+			}
+		} else {
+			he = echo.NewHTTPError(http.StatusInternalServerError)
+		}
+
+		id := c.Response().Header().Get(echo.HeaderXRequestID)
+		if id == "" {
+			e.DefaultHTTPErrorHandler(he, c)
+			return
+		}
+
+		// the message is copied, as errors may be shared
+		message := map[string]interface{}{}
+		switch m := he.Message.(type) {
+		case string:
+			message["message"] = m
+		case map[string]interface{}:
+			for k, v := range m {
+				message[k] = v
+			}
+		case echo.Map:
+			for k, v := range m {
+				message[k] = v
+			}
+		default:
+			e.DefaultHTTPErrorHandler(he, c)
+			return
+		}
+		message["request_id"] = id
+
+		e.DefaultHTTPErrorHandler(&echo.HTTPError{Code: he.Code, Message: message}, c)
+	}
+}
@@ -0,0 +1,60 @@
+package server_test
+
+import (
+	"encoding/json"
+	"errors"
+	"go-blog/pkg/util/exception"
+	"go-blog/pkg/util/server"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorHandler(t *testing.T) {
+	cases := []struct {
+		name        string
+		err         error
+		requestID   bool
+		wantStatus  int
+		wantMessage string
+		wantCode    string
+	}{
+		{name: "Message", err: echo.NewHTTPError(http.StatusNotFound), requestID: true, wantStatus: http.StatusNotFound, wantMessage: "Not Found"},
+		{name: "Error map", err: echo.NewHTTPError(http.StatusBadRequest, exception.GetErrorMap(exception.CodeInvalidPage, "")), requestID: true, wantStatus: http.StatusBadRequest, wantMessage: "invalid page value", wantCode: exception.CodeInvalidPage},
+		{name: "Internal error", err: errors.New("database is locked"), requestID: true, wantStatus: http.StatusInternalServerError, wantMessage: "Internal Server Error"},
+		{name: "No request ID", err: echo.NewHTTPError(http.StatusNotFound), wantStatus: http.StatusNotFound, wantMessage: "Not Found"},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			e.HTTPErrorHandler = server.ErrorHandler(e)
+			if tt.requestID {
+				e.Use(middleware.RequestID())
+			}
+			e.GET("/posts", func(c echo.Context) error { return tt.err })
+
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/posts", nil))
+
+			var body map[string]interface{}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Equal(t, tt.wantMessage, body["message"])
+			if tt.wantCode != "" {
+				assert.Equal(t, tt.wantCode, body["code"])
+			}
+			if tt.requestID {
+				assert.Equal(t, rec.Header().Get(echo.HeaderXRequestID), body["request_id"])
+			} else {
+				assert.NotContains(t, body, "request_id")
+			}
+		})
+	}
+}
@@ -1,22 +1,22 @@
 package server
 
 import (
+	"go-blog/pkg/util/maintenance"
 	"net/http"
 	"os"
 
 	"github.com/labstack/echo/v4"
-	"github.com/rwbm/go-tools/files"
 )
 
 // Local vars
 var (
-	AppVersion          string
-	HostName            string
-	AppName             = "Mercury API"
-	ComponentName       = "mercury-api"
-	MaintenanceFilePath = "/etc/app-mode/maintenance"
-	StatusOperational   = "Operational"
-	StatusMaintenance   = "Maintenance"
+	AppVersion        string
+	HostName          string
+	AppName           = "Mercury API"
+	ComponentName     = "mercury-api"
+	MaintenanceMode   = maintenance.New(maintenance.DefaultFile)
+	StatusOperational = "Operational"
+	StatusMaintenance = "Maintenance"
 )
 
 type appStatus struct {
@@ -29,7 +29,7 @@ type appStatus struct {
 func healthCheckHandler(c echo.Context) error {
 
 	responseCode := http.StatusOK
-	if files.Exists(MaintenanceFilePath) {
+	if MaintenanceMode.Enabled() {
 		responseCode = http.StatusFound
 	}
 
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+)
+
+// first file descriptor passed by systemd, as described in sd_listen_fds(3)
+const listenFDsStart = 3
+
+// returns the listener requests are received on: the socket passed by systemd, if socket
+// activation is set, or a Unix socket, if set, or the TCP port otherwise
+func listen(cfg *Config) (net.Listener, error) {
+	switch {
+	case cfg.SocketActivation:
+		return systemdListener()
+
+	case cfg.Socket != "":
+		// a socket left by a previous run that didn't stop cleanly is removed, unless it's
+		// still in use
+		if info, err := os.Stat(cfg.Socket); err == nil && info.Mode()&os.ModeSocket != 0 {
+			if conn, err := net.Dial("unix", cfg.Socket); err == nil {
+				conn.Close()
+				return nil, fmt.Errorf("socket %s is already in use", cfg.Socket)
+			}
+			os.Remove(cfg.Socket)
+		}
+		return net.Listen("unix", cfg.Socket)
+
+	default:
+		return net.Listen("tcp", cfg.Port)
+	}
+}
+
+// returns a listener for the first socket passed by systemd; sockets after the first one
+// are ignored
+func systemdListener() (net.Listener, error) {
+	pid, _ := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	fds, _ := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if pid != os.Getpid() || fds < 1 {
+		return nil, errors.New("no socket was passed by systemd; the service must be started by a socket unit")
+	}
+
+	// the sockets are not passed on to child processes
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	f := os.NewFile(listenFDsStart, "LISTEN_FD_"+strconv.Itoa(listenFDsStart))
+	defer f.Close()
+
+	return net.FileListener(f)
+}
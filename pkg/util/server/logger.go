@@ -0,0 +1,57 @@
+package server
+
+import (
+	"go-blog/pkg/util/log"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// RequestLogger returns a middleware that logs each request served, with its ID, client,
+// route, status and latency, using the service logger; server errors are logged as errors,
+// and client errors as warnings
+func RequestLogger(logger *log.Log) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+
+			start := time.Now()
+
+			// errors are written here, so the status and size of the response are known
+			err := next(c)
+			if err != nil {
+				c.Error(err)
+			}
+
+			req, res := c.Request(), c.Response()
+			params := map[string]interface{}{
+				"request_id": res.Header().Get(echo.HeaderXRequestID),
+				"method":     req.Method,
+				"uri":        req.RequestURI,
+				"route":      routeLabel(c, res.Status),
+				"status":     res.Status,
+				"latency_ms": float64(time.Since(start).Microseconds()) / 1000,
+				"bytes_out":  res.Size,
+				"remote_ip":  c.RealIP(),
+				"user_agent": req.UserAgent(),
+			}
+			if id, ok := c.Get("client_id").(int); ok {
+				params["client_id"] = id
+			}
+
+			switch {
+			case res.Status >= http.StatusInternalServerError:
+				logger.Error("request failed", err, params)
+			case res.Status >= http.StatusBadRequest:
+				if err != nil {
+					params["error"] = err.Error()
+				}
+				logger.Warn("request rejected", params)
+			default:
+				logger.Info("request served", params)
+			}
+
+			return err
+		}
+	}
+}
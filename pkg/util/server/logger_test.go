@@ -0,0 +1,77 @@
+package server_test
+
+import (
+	"encoding/json"
+	"go-blog/pkg/util/log"
+	"go-blog/pkg/util/server"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestLogger(t *testing.T) {
+	cases := []struct {
+		name       string
+		method     string
+		path       string
+		wantLevel  string
+		wantRoute  string
+		wantStatus float64
+		wantError  string
+	}{
+		{name: "Served", method: http.MethodGet, path: "/posts/10", wantLevel: "info", wantRoute: "/posts/:id", wantStatus: http.StatusOK},
+		{name: "Rejected", method: http.MethodGet, path: "/wp-login.php", wantLevel: "warn", wantRoute: "none", wantStatus: http.StatusNotFound, wantError: "code=404, message=Not Found"},
+		{name: "Failed", method: http.MethodPost, path: "/posts/10", wantLevel: "error", wantRoute: "/posts/:id", wantStatus: http.StatusServiceUnavailable, wantError: "code=503, message=Service Unavailable"},
+	}
+
+	dir, err := ioutil.TempDir("", "logger")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(dir, tt.name+".log")
+			closeLog, err := log.Setup(log.Config{Level: "debug", Output: log.OutputFile, File: file})
+			require.NoError(t, err)
+			defer log.Setup(log.Config{Level: "debug"})
+
+			e := echo.New()
+			e.Use(server.RequestLogger(log.New()), middleware.RequestID())
+			e.GET("/posts/:id", func(c echo.Context) error { return c.String(http.StatusOK, "post") })
+			e.POST("/posts/:id", func(c echo.Context) error { return echo.NewHTTPError(http.StatusServiceUnavailable) })
+
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+			require.NoError(t, closeLog())
+
+			content, err := ioutil.ReadFile(file)
+			require.NoError(t, err)
+
+			var entry map[string]interface{}
+			require.NoError(t, json.Unmarshal(content, &entry), "a single JSON line is expected")
+
+			assert.Equal(t, tt.wantLevel, entry["level"])
+			assert.Equal(t, tt.method, entry["method"])
+			assert.Equal(t, tt.path, entry["uri"])
+			assert.Equal(t, tt.wantRoute, entry["route"])
+			assert.Equal(t, tt.wantStatus, entry["status"])
+			assert.Equal(t, rec.Header().Get(echo.HeaderXRequestID), entry["request_id"])
+			assert.NotEmpty(t, entry["request_id"])
+			assert.Contains(t, entry, "latency_ms")
+			assert.Contains(t, entry, "time")
+			if tt.wantError != "" {
+				assert.Equal(t, tt.wantError, entry["error"])
+			} else {
+				assert.NotContains(t, entry, "error")
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"go-blog/pkg/util/exception"
+	"go-blog/pkg/util/maintenance"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Maintenance returns a middleware that keeps the API read-only while maintenance is on:
+// requests that may change data get a 503 response, with a Retry-After header asking
+// clients to wait for retryAfter. Requests to paths starting with one of skipPrefixes,
+// like the admin endpoints used to turn maintenance off, are always let through.
+func Maintenance(mode *maintenance.Mode, retryAfter time.Duration, skipPrefixes ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+
+			switch c.Request().Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				return next(c)
+			}
+
+			for _, prefix := range skipPrefixes {
+				if strings.HasPrefix(c.Path(), prefix) {
+					return next(c)
+				}
+			}
+
+			if !mode.Enabled() {
+				return next(c)
+			}
+
+			c.Response().Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+			return echo.NewHTTPError(
+				http.StatusServiceUnavailable,
+				exception.GetErrorMap(exception.CodeMaintenance, ""))
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package server_test
+
+import (
+	"go-blog/pkg/util/maintenance"
+	"go-blog/pkg/util/server"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaintenance(t *testing.T) {
+	dir, err := ioutil.TempDir("", "maintenance")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	mode := maintenance.New(filepath.Join(dir, "app-mode", "maintenance"))
+
+	cases := []struct {
+		name           string
+		enabled        bool
+		method         string
+		path           string
+		wantCode       int
+		wantRetryAfter string
+	}{
+		{name: "Write", method: http.MethodPost, path: "/posts", wantCode: http.StatusOK},
+		{name: "Read during maintenance", enabled: true, method: http.MethodGet, path: "/posts", wantCode: http.StatusOK},
+		{name: "Write during maintenance", enabled: true, method: http.MethodPost, path: "/posts", wantCode: http.StatusServiceUnavailable, wantRetryAfter: "120"},
+		{name: "Delete during maintenance", enabled: true, method: http.MethodDelete, path: "/posts", wantCode: http.StatusServiceUnavailable, wantRetryAfter: "120"},
+		{name: "Admin during maintenance", enabled: true, method: http.MethodDelete, path: "/admin/maintenance", wantCode: http.StatusOK},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.enabled {
+				assert.NoError(t, mode.Enable())
+				assert.True(t, mode.Status().Enabled)
+				assert.NotNil(t, mode.Status().Since)
+			} else {
+				assert.NoError(t, mode.Disable())
+				assert.False(t, mode.Status().Enabled)
+			}
+
+			e := echo.New()
+			e.Use(server.Maintenance(mode, 2*time.Minute, "/admin/"))
+			ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+			e.GET("/posts", ok)
+			e.POST("/posts", ok)
+			e.DELETE("/posts", ok)
+			e.DELETE("/admin/maintenance", ok)
+
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			assert.Equal(t, tt.wantRetryAfter, rec.Header().Get("Retry-After"))
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"go-blog/pkg/util/metrics"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Metrics returns a middleware that records the number and duration of requests, by
+// method, route and status code; routes are the registered paths, like /posts/:id, so
+// URLs with different parameters are counted together
+func Metrics() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+
+			start := time.Now()
+			err := next(c)
+
+			status := responseStatus(c, err)
+
+			labels := []string{c.Request().Method, routeLabel(c, status), strconv.Itoa(status)}
+			metrics.HTTPRequests.WithLabelValues(labels...).Inc()
+			metrics.HTTPRequestDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+
+			return err
+		}
+	}
+}
+
+// returns the status code of the response; errors are written once the middleware chain
+// ends, so their status is taken from the error itself
+func responseStatus(c echo.Context, err error) int {
+	if err == nil {
+		return c.Response().Status
+	}
+	if he, ok := err.(*echo.HTTPError); ok {
+		return he.Code
+	}
+	return http.StatusInternalServerError
+}
+
+// returns the route of the request; requests that match no route have the request path
+// as route, so they are all labeled as none to keep the number of series bounded
+func routeLabel(c echo.Context, status int) string {
+	route := c.Path()
+	if status != http.StatusNotFound || route != c.Request().URL.Path {
+		return route
+	}
+
+	for _, r := range c.Echo().Routes() {
+		if r.Path == route {
+			return route
+		}
+	}
+	return "none"
+}
@@ -0,0 +1,41 @@
+package server_test
+
+import (
+	"go-blog/pkg/util/metrics"
+	"go-blog/pkg/util/server"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	cases := []struct {
+		name       string
+		method     string
+		path       string
+		wantLabels []string
+	}{
+		{name: "Route", method: http.MethodGet, path: "/posts/10", wantLabels: []string{http.MethodGet, "/posts/:id", "200"}},
+		{name: "Error", method: http.MethodPost, path: "/posts/10", wantLabels: []string{http.MethodPost, "/posts/:id", "503"}},
+		{name: "Not found", method: http.MethodGet, path: "/wp-login.php", wantLabels: []string{http.MethodGet, "none", "404"}},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			e.Use(server.Metrics())
+			e.GET("/posts/:id", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+			e.POST("/posts/:id", func(c echo.Context) error { return echo.NewHTTPError(http.StatusServiceUnavailable) })
+
+			before := testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues(tt.wantLabels...))
+
+			e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, tt.path, nil))
+
+			assert.Equal(t, before+1, testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues(tt.wantLabels...)))
+		})
+	}
+}
@@ -0,0 +1,14 @@
+package server
+
+import "github.com/labstack/echo/v4"
+
+// Reloadable returns a middleware that runs the one returned by build for each request,
+// so middlewares with settings that may change while the service runs, like timeouts,
+// always use the current ones
+func Reloadable(build func() echo.MiddlewareFunc) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			return build()(next)(c)
+		}
+	}
+}
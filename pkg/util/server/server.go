@@ -2,15 +2,18 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
 	"go-blog/pkg/util/log"
+	"net"
 	"net/http"
-	"os"
-	"os/signal"
 	"time"
 
 	"github.com/go-playground/validator"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 // Config represents server specific config
@@ -19,6 +22,22 @@ type Config struct {
 	Port                string
 	ReadTimeoutSeconds  int
 	WriteTimeoutSeconds int
+	IdleTimeoutSeconds  int  // time idle connections are kept; 0 uses ReadTimeoutSeconds
+	MaxHeaderBytes      int  // size limit of request headers; 0 uses http.DefaultMaxHeaderBytes
+	KeepAlive           bool // if not set, connections are closed after each request
+	HTTP2               bool // HTTP/2 is served over TLS, or in clear text with no TLS
+
+	// TLS is served if both files are set; they are loaded again when they change
+	TLSCertFile string
+	TLSKeyFile  string
+
+	// requests are received on the socket passed by systemd, if SocketActivation is set,
+	// or on the Unix socket at Socket, if set, or on Port otherwise
+	Socket           string
+	SocketActivation bool
+
+	// time given to requests being served to end, once the server is stopped
+	ShutdownTimeoutSeconds int
 }
 
 // New instantates new Echo server
@@ -27,11 +46,16 @@ func New() *echo.Echo {
 	e := echo.New()
 
 	e.Use(
-		middleware.Logger(),    // default echo logger
-		middleware.Recover(),   // recover from panics
-		middleware.RequestID(), // generate ID for requests --> TODO: chequear skips, por ej /health
+		RequestLogger(log.New()), // log requests with the service logger
+		middleware.Recover(),     // recover from panics
+		middleware.RequestID(),   // generate ID for requests --> TODO: chequear skips, por ej /health
+		Metrics(),                // count requests and their duration
+		Tracing(),                // record a span for each request
 	)
 
+	// errors include the request ID
+	e.HTTPErrorHandler = ErrorHandler(e)
+
 	// default validator
 	e.Validator = &CustomValidator{V: validator.New()}
 	e.Binder = &CustomBinder{b: &echo.DefaultBinder{}}
@@ -39,43 +63,90 @@ func New() *echo.Echo {
 	// health check
 	e.GET("/health", healthCheckHandler)
 
+	// metrics for Prometheus
+	e.GET("/metrics", echo.WrapHandler(promhttp.Handler()))
+
 	return e
 }
 
-// Start starts echo server
-func Start(e *echo.Echo, cfg *Config, log *log.Log) {
+// Start starts echo server, and serves requests until ctx is done; then, requests being
+// served are given up to cfg.ShutdownTimeoutSeconds to end. It returns an error if the
+// server can't be started, or it stops unexpectedly.
+func Start(ctx context.Context, e *echo.Echo, cfg *Config, log *log.Log) (err error) {
+
+	// requests use a context that is canceled once shutdown ends, so queries still
+	// running at that point are canceled
+	baseCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+
+	e.Debug = false
 
 	s := &http.Server{
-		Addr:         cfg.Port,
-		ReadTimeout:  time.Duration(cfg.ReadTimeoutSeconds) * time.Second,
-		WriteTimeout: time.Duration(cfg.WriteTimeoutSeconds) * time.Second,
+		Handler:        e,
+		ReadTimeout:    time.Duration(cfg.ReadTimeoutSeconds) * time.Second,
+		WriteTimeout:   time.Duration(cfg.WriteTimeoutSeconds) * time.Second,
+		IdleTimeout:    time.Duration(cfg.IdleTimeoutSeconds) * time.Second,
+		MaxHeaderBytes: cfg.MaxHeaderBytes,
+		ErrorLog:       e.StdLogger,
+		BaseContext:    func(net.Listener) context.Context { return baseCtx },
 	}
-	s.SetKeepAlivesEnabled(false)
+	s.SetKeepAlivesEnabled(cfg.KeepAlive)
 
-	e.Debug = false
-	e.HideBanner = true
-	e.HidePort = true
+	if cfg.TLSCertFile != "" && cfg.TLSKeyFile != "" {
+		cert, errCert := LoadCertificate(cfg.TLSCertFile, cfg.TLSKeyFile, log)
+		if errCert != nil {
+			log.Error("error starting the server:", errCert, nil)
+			return errCert
+		}
+		go cert.Start(ctx)
+
+		s.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: cert.GetCertificate,
+		}
+	}
+
+	switch {
+	case !cfg.HTTP2:
+		// HTTP/2 is negotiated over TLS, unless this is set
+		s.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+	case s.TLSConfig == nil:
+		s.Handler = h2c.NewHandler(e, &http2.Server{IdleTimeout: s.IdleTimeout})
+	}
+
+	listener, err := listen(cfg)
+	if err != nil {
+		log.Error("error starting the server:", err, nil)
+		return
+	}
 
 	// start server
-	log.Info("starting "+cfg.ServiceName, nil)
+	log.Info("starting "+cfg.ServiceName, map[string]interface{}{"address": listener.Addr().String(), "tls": s.TLSConfig != nil, "http2": cfg.HTTP2})
+	serveErr := make(chan error, 1)
 	go func() {
-		if err := e.StartServer(s); err != nil {
-			log.Error("error starting the server:", err, nil)
-			return
+		if s.TLSConfig != nil {
+			serveErr <- s.ServeTLS(listener, "", "")
+		} else {
+			serveErr <- s.Serve(listener)
 		}
 	}()
 
-	// wait for interrupt signal to gracefully shutdown the server with
-	// a timeout of 10 seconds.
-	quit := make(chan os.Signal)
-	signal.Notify(quit, os.Interrupt)
-	<-quit
+	select {
+	case err = <-serveErr:
+		log.Error("error starting the server:", err, nil)
+		return
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	case <-ctx.Done():
+	}
+
+	// stop accepting requests, and wait for the ones being served
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.ShutdownTimeoutSeconds)*time.Second)
 	defer cancel()
-	if err := e.Shutdown(ctx); err != nil {
+	if err = s.Shutdown(shutdownCtx); err != nil {
 		log.Error("error stopping server", err, nil)
 	} else {
 		log.Info(cfg.ServiceName+" stoped!", nil)
 	}
+
+	return
 }
@@ -1,8 +1,22 @@
 package server_test
 
 import (
+	"context"
+	"crypto/tls"
+	"go-blog/pkg/util/log"
 	"go-blog/pkg/util/server"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
 )
 
 func TestNew(t *testing.T) {
@@ -11,3 +25,81 @@ func TestNew(t *testing.T) {
 		t.Errorf("Server should not be nil")
 	}
 }
+
+func TestStart(t *testing.T) {
+	cases := []struct {
+		name      string
+		keepAlive bool
+		http2     bool
+		tls       bool
+		wantProto string
+	}{
+		{name: "Unix socket", wantProto: "HTTP/1.1"},
+		{name: "Keep-alive", keepAlive: true, wantProto: "HTTP/1.1"},
+		{name: "TLS", keepAlive: true, tls: true, wantProto: "HTTP/1.1"},
+		{name: "TLS and HTTP/2", keepAlive: true, http2: true, tls: true, wantProto: "HTTP/2.0"},
+		{name: "HTTP/2 with no TLS", keepAlive: true, http2: true, wantProto: "HTTP/2.0"},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "server")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			cfg := &server.Config{
+				ServiceName:            "test",
+				KeepAlive:              tt.keepAlive,
+				HTTP2:                  tt.http2,
+				Socket:                 filepath.Join(dir, "server.sock"),
+				ShutdownTimeoutSeconds: 1,
+			}
+			if tt.tls {
+				cfg.TLSCertFile, cfg.TLSKeyFile = writeCertificate(t, dir, "localhost")
+			}
+
+			e := echo.New()
+			e.GET("/posts", func(c echo.Context) error { return c.String(http.StatusOK, "posts") })
+
+			ctx, stop := context.WithCancel(context.Background())
+			done := make(chan error, 1)
+			go func() { done <- server.Start(ctx, e, cfg, log.New()) }()
+
+			dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", cfg.Socket)
+			}
+			var client http.Client
+			url := "http://localhost/posts"
+			switch {
+			case tt.tls:
+				url = "https://localhost/posts"
+				client.Transport = &http.Transport{DialContext: dial, TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, ForceAttemptHTTP2: true}
+			case tt.http2:
+				client.Transport = &http2.Transport{AllowHTTP: true, DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+					return dial(context.Background(), network, addr)
+				}}
+			default:
+				client.Transport = &http.Transport{DialContext: dial}
+			}
+
+			require.Eventually(t, func() bool {
+				_, err := os.Stat(cfg.Socket)
+				return err == nil
+			}, time.Second, 10*time.Millisecond)
+
+			res, err := client.Get(url)
+			require.NoError(t, err)
+			res.Body.Close()
+
+			assert.Equal(t, http.StatusOK, res.StatusCode)
+			assert.Equal(t, tt.wantProto, res.Proto)
+			if tt.wantProto == "HTTP/1.1" {
+				assert.Equal(t, !tt.keepAlive, res.Close)
+			}
+
+			stop()
+			assert.NoError(t, <-done)
+			assert.NoFileExists(t, cfg.Socket)
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// RequestTimeout returns a middleware that cancels the request context once timeout
+// expires, so database queries of slow requests are canceled; handlers are expected
+// to stop when the context is done. It does nothing if timeout is not positive, or for
+// requests to paths starting with one of skipPrefixes, like admin tasks that may take longer.
+func RequestTimeout(timeout time.Duration, skipPrefixes ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if timeout <= 0 {
+				return next(c)
+			}
+
+			for _, prefix := range skipPrefixes {
+				if strings.HasPrefix(c.Path(), prefix) {
+					return next(c)
+				}
+			}
+
+			ctx, cancel := context.WithTimeout(c.Request().Context(), timeout)
+			defer cancel()
+
+			c.SetRequest(c.Request().WithContext(ctx))
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package server_test
+
+import (
+	"context"
+	"go-blog/pkg/util/server"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestTimeout(t *testing.T) {
+	cases := []struct {
+		name        string
+		timeout     time.Duration
+		path        string
+		wantExpired bool
+	}{
+		{name: "Expired", timeout: time.Millisecond, path: "/posts", wantExpired: true},
+		{name: "Disabled", timeout: 0, path: "/posts"},
+		{name: "Skipped", timeout: time.Millisecond, path: "/admin/backup"},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			c := e.NewContext(httptest.NewRequest(http.MethodGet, tt.path, nil), httptest.NewRecorder())
+			c.SetPath(tt.path)
+
+			var errCtx error
+			handler := server.RequestTimeout(tt.timeout, "/admin/")(func(c echo.Context) error {
+				select {
+				case <-c.Request().Context().Done():
+				case <-time.After(50 * time.Millisecond):
+				}
+				errCtx = c.Request().Context().Err()
+				return nil
+			})
+
+			assert.NoError(t, handler(c))
+			if tt.wantExpired {
+				assert.Equal(t, context.DeadlineExceeded, errCtx)
+			} else {
+				assert.NoError(t, errCtx)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"go-blog/pkg/util/log"
+	"os"
+	"sync"
+	"time"
+)
+
+// CertificateCheckInterval is the time between checks for changes to the certificate files
+const CertificateCheckInterval = 5 * time.Second
+
+// Certificate holds the TLS certificate served, which is loaded again each time its files
+// change, so renewed certificates are served with no restart
+type Certificate struct {
+	certFile string
+	keyFile  string
+	logger   *log.Log
+
+	mu    sync.RWMutex
+	cert  *tls.Certificate
+	state string // modification time and size of the files when they were last loaded
+}
+
+// LoadCertificate loads a certificate and its key from a pair of PEM encoded files
+func LoadCertificate(certFile, keyFile string, logger *log.Log) (c *Certificate, err error) {
+	c = &Certificate{
+		certFile: certFile,
+		keyFile:  keyFile,
+		logger:   logger,
+	}
+	err = c.Reload()
+	return
+}
+
+// GetCertificate returns the certificate in use; it's meant to be set as the
+// GetCertificate function of a tls.Config
+func (c *Certificate) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.cert, nil
+}
+
+// Start loads the certificate again each time its files change, until ctx is done
+func (c *Certificate) Start(ctx context.Context) {
+	ticker := time.NewTicker(CertificateCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.mu.RLock()
+			changed := c.filesState() != c.state
+			c.mu.RUnlock()
+
+			if changed {
+				c.logger.Info("TLS certificate files changed; reloading them", map[string]interface{}{"cert_file": c.certFile, "key_file": c.keyFile})
+				c.Reload()
+			}
+
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Reload loads the certificate files again; if they can't be loaded, the certificate in
+// use is kept, and they are not loaded again until they change
+func (c *Certificate) Reload() (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.state = c.filesState()
+
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		err = fmt.Errorf("error loading TLS certificate: %s", err)
+		if c.cert != nil {
+			c.logger.Error("error reloading TLS certificate; the current one is kept", err, map[string]interface{}{"cert_file": c.certFile, "key_file": c.keyFile})
+		}
+		return
+	}
+	c.cert = &cert
+
+	return
+}
+
+// returns the modification time and size of the certificate files, to find out if they
+// changed
+func (c *Certificate) filesState() (state string) {
+	for _, file := range []string{c.certFile, c.keyFile} {
+		if info, err := os.Stat(file); err == nil {
+			state += fmt.Sprintf("%d/%d;", info.ModTime().UnixNano(), info.Size())
+		} else {
+			state += "-;"
+		}
+	}
+	return
+}
@@ -0,0 +1,77 @@
+package server_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"go-blog/pkg/util/log"
+	"go-blog/pkg/util/server"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writes a self-signed certificate for localhost, and its key, to dir
+func writeCertificate(t *testing.T, dir, commonName string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	require.NoError(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644))
+	require.NoError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return
+}
+
+// returns the common name of the certificate served
+func servedName(t *testing.T, cert *server.Certificate) string {
+	c, err := cert.GetCertificate(nil)
+	require.NoError(t, err)
+	parsed, err := x509.ParseCertificate(c.Certificate[0])
+	require.NoError(t, err)
+	return parsed.Subject.CommonName
+}
+
+func TestCertificate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tls")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile := writeCertificate(t, dir, "first")
+	cert, err := server.LoadCertificate(certFile, keyFile, log.New())
+	require.NoError(t, err)
+	assert.Equal(t, "first", servedName(t, cert))
+
+	// renewed certificates are served once reloaded
+	writeCertificate(t, dir, "renewed")
+	require.NoError(t, cert.Reload())
+	assert.Equal(t, "renewed", servedName(t, cert))
+
+	// invalid files are not served
+	require.NoError(t, ioutil.WriteFile(keyFile, []byte("not a key"), 0600))
+	assert.Error(t, cert.Reload())
+	assert.Equal(t, "renewed", servedName(t, cert))
+
+	_, err = server.LoadCertificate(certFile, filepath.Join(dir, "missing.pem"), log.New())
+	assert.Error(t, err)
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"go-blog/pkg/util/tracing"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+)
+
+// Tracing returns a middleware that records a span for each request, continuing the trace
+// sent by the client in the traceparent header, if any. The request ID set by the RequestID
+// middleware is added to the span, and to the request context, so spans started while
+// serving the request include it too.
+func Tracing() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+
+			req := c.Request()
+			ctx := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+			ctx = tracing.WithRequestID(ctx, c.Response().Header().Get(echo.HeaderXRequestID))
+
+			ctx, span := tracing.Start(ctx, "HTTP "+req.Method, semconv.HTTPMethodKey.String(req.Method))
+			c.SetRequest(req.WithContext(ctx))
+
+			err := next(c)
+
+			status := responseStatus(c, err)
+
+			route := routeLabel(c, status)
+			span.SetName(req.Method + " " + route)
+			span.SetAttributes(semconv.HTTPRouteKey.String(route), semconv.HTTPStatusCodeKey.Int(status))
+
+			// client errors are not errors of the service
+			if status >= http.StatusInternalServerError && err != nil {
+				tracing.End(span, err)
+			} else {
+				span.End()
+			}
+
+			return err
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package server_test
+
+import (
+	"go-blog/pkg/util/server"
+	"go-blog/pkg/util/tracing"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+)
+
+func TestTracing(t *testing.T) {
+	cases := []struct {
+		name       string
+		method     string
+		path       string
+		wantName   string
+		wantStatus int
+		wantError  bool
+	}{
+		{name: "Route", method: http.MethodGet, path: "/posts/10", wantName: "GET /posts/:id", wantStatus: http.StatusOK},
+		{name: "Server error", method: http.MethodPost, path: "/posts/10", wantName: "POST /posts/:id", wantStatus: http.StatusServiceUnavailable, wantError: true},
+		{name: "Not found", method: http.MethodGet, path: "/wp-login.php", wantName: "GET none", wantStatus: http.StatusNotFound},
+	}
+
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			e.Use(middleware.RequestID(), server.Tracing())
+			e.GET("/posts/:id", func(c echo.Context) error {
+				// spans started by handlers are children of the request span
+				_, span := tracing.Start(c.Request().Context(), "handler")
+				span.End()
+				return c.NoContent(http.StatusOK)
+			})
+			e.POST("/posts/:id", func(c echo.Context) error { return echo.NewHTTPError(http.StatusServiceUnavailable) })
+
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+
+			spans := recorder.Ended()
+			if !assert.NotEmpty(t, spans) {
+				return
+			}
+			span := spans[len(spans)-1]
+
+			assert.Equal(t, tt.wantName, span.Name())
+			attrs := attribute.NewSet(span.Attributes()...)
+			status, _ := attrs.Value(semconv.HTTPStatusCodeKey)
+			assert.Equal(t, int64(tt.wantStatus), status.AsInt64())
+			requestID, _ := attrs.Value(tracing.RequestIDKey)
+			assert.Equal(t, rec.Header().Get(echo.HeaderXRequestID), requestID.AsString())
+			assert.Equal(t, tt.wantError, span.Status().Code == codes.Error)
+
+			if tt.wantStatus == http.StatusOK {
+				child := spans[len(spans)-2]
+				assert.Equal(t, span.SpanContext().SpanID(), child.Parent().SpanID())
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package slug
+
+import (
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// MaxLength is the max number of characters of a slug, as stored in the database
+const MaxLength = 128
+
+// Make returns the slug for the indicated name: letters and digits are kept in lower
+// case, and any other sequence of characters is replaced with a single dash. Names
+// with no letters or digits are hex encoded, so they still get a slug. Slugs are cut
+// to MaxLength characters. Different names may get the same slug, like C++ and C#;
+// use Unique to tell them apart.
+func Make(name string) string {
+	var sb strings.Builder
+	dash := false
+
+	for _, r := range strings.TrimSpace(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && sb.Len() > 0 {
+				sb.WriteRune('-')
+			}
+			sb.WriteRune(unicode.ToLower(r))
+			dash = false
+			continue
+		}
+		dash = true
+	}
+
+	if sb.Len() == 0 && strings.TrimSpace(name) != "" {
+		return truncate(hex.EncodeToString([]byte(strings.TrimSpace(name))), MaxLength)
+	}
+
+	return truncate(sb.String(), MaxLength)
+}
+
+// Unique returns the slug for the indicated name, followed by the lowest numeric suffix
+// needed to make it unique, like c-2, if taken returns true for it; the suffix is kept
+// within MaxLength characters
+func Unique(name string, taken func(slug string) (bool, error)) (slug string, err error) {
+	base := Make(name)
+	slug = base
+
+	for n := 2; ; n++ {
+		found, errTaken := taken(slug)
+		if errTaken != nil || !found {
+			return slug, errTaken
+		}
+
+		suffix := "-" + strconv.Itoa(n)
+		slug = truncate(base, MaxLength-len(suffix)) + suffix
+	}
+}
+
+// cuts s to max characters, with no dash left at the end
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return strings.TrimRight(string(runes[:max]), "-")
+}
@@ -0,0 +1,58 @@
+package slug_test
+
+import (
+	"go-blog/pkg/util/slug"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMake(t *testing.T) {
+	cases := map[string]string{
+		"Go Programming":                "go-programming",
+		"  DevOps / AWS  ":              "devops-aws",
+		"C++":                           "c",
+		"Programación en Go!":           "programación-en-go",
+		"++":                            "2b2b",
+		"":                              "",
+		strings.Repeat("a", 130):        strings.Repeat("a", 128),
+		strings.Repeat("a", 127) + " b": strings.Repeat("a", 127),
+		strings.Repeat("+", 100):        strings.Repeat("2b", 64),
+	}
+
+	for name, want := range cases {
+		assert.Equal(t, want, slug.Make(name), name)
+	}
+}
+
+func TestUnique(t *testing.T) {
+	long := strings.Repeat("á", 130)
+
+	cases := []struct {
+		name  string
+		taken []string
+		want  string
+	}{
+		{name: "C", want: "c"},
+		{name: "C#", taken: []string{"c"}, want: "c-2"},
+		{name: "C++", taken: []string{"c", "c-2"}, want: "c-3"},
+		{name: long, taken: []string{strings.Repeat("á", 128)}, want: strings.Repeat("á", 126) + "-2"},
+	}
+
+	for _, tt := range cases {
+		got, err := slug.Unique(tt.name, func(s string) (bool, error) {
+			for i := range tt.taken {
+				if tt.taken[i] == s {
+					return true, nil
+				}
+			}
+			return false, nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, got)
+		assert.True(t, utf8.RuneCountInString(got) <= slug.MaxLength)
+	}
+}
@@ -0,0 +1,49 @@
+package template
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// DateOnlyFormat is the layout used to parse dates without time from template
+	DateOnlyFormat = "2006-01-02"
+)
+
+// DateLayouts contains the layouts accepted for template dates, in the order they
+// are tried. RFC 3339 dates include their own offset; the rest of them are
+// interpreted in the template timezone.
+var DateLayouts = []string{
+	time.RFC3339,
+	DateFormat,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	DateOnlyFormat,
+}
+
+// ParseDate parses a template date using any of the accepted layouts. Dates
+// without offset are interpreted in loc; the result is always returned in UTC.
+func ParseDate(value string, loc *time.Location) (t time.Time, err error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	value = strings.TrimSpace(value)
+	for _, layout := range DateLayouts {
+		if t, err = time.ParseInLocation(layout, value, loc); err == nil {
+			return t.UTC(), nil
+		}
+	}
+
+	return t, fmt.Errorf("invalid date '%s'; expected RFC 3339, '%s' or '%s' format", value, DateFormat, DateOnlyFormat)
+}
+
+// LoadLocation returns the timezone with the indicated name; empty names
+// are taken as UTC
+func LoadLocation(name string) (*time.Location, error) {
+	if strings.TrimSpace(name) == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(strings.TrimSpace(name))
+}
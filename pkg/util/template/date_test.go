@@ -0,0 +1,77 @@
+package template
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDate(t *testing.T) {
+	buenosAires, err := time.LoadLocation("America/Argentina/Buenos_Aires")
+	assert.NoError(t, err)
+
+	cases := []struct {
+		name    string
+		value   string
+		loc     *time.Location
+		want    time.Time
+		wantErr bool
+	}{
+		{
+			name:  "Legacy format",
+			value: "2020-04-15 12:09:57",
+			want:  time.Date(2020, 4, 15, 12, 9, 57, 0, time.UTC),
+		},
+		{
+			name:  "Legacy format in timezone",
+			value: "2020-04-15 12:09:57",
+			loc:   buenosAires,
+			want:  time.Date(2020, 4, 15, 15, 9, 57, 0, time.UTC),
+		},
+		{
+			name:  "RFC 3339 ignores timezone",
+			value: "2020-04-15T12:09:57+02:00",
+			loc:   buenosAires,
+			want:  time.Date(2020, 4, 15, 10, 9, 57, 0, time.UTC),
+		},
+		{
+			name:  "Date only",
+			value: "2020-04-15",
+			loc:   buenosAires,
+			want:  time.Date(2020, 4, 15, 3, 0, 0, 0, time.UTC),
+		},
+		{
+			name:    "Invalid date",
+			value:   "15/04/2020",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDate(tt.value, tt.loc)
+			assert.Equal(t, tt.wantErr, err != nil)
+			if !tt.wantErr {
+				assert.True(t, tt.want.Equal(got))
+				assert.Equal(t, time.UTC, got.Location())
+			}
+		})
+	}
+}
+
+func TestParseTemplateTimezone(t *testing.T) {
+	htmlExample := `
+<head>
+	<meta name="title" content="My First Blog Post"/>
+	<meta name="author" content="John Doe"/>
+	<meta name="timezone" content="America/Argentina/Buenos_Aires"/>
+	<meta name="post-date" content="2020-04-15 12:09:57"/>
+</head>
+<body></body>
+	`
+
+	post, err := ParseTemplateInLocation(htmlExample, time.UTC)
+	assert.NoError(t, err)
+	assert.True(t, time.Date(2020, 4, 15, 15, 9, 57, 0, time.UTC).Equal(post.DateCreated))
+}
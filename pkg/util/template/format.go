@@ -0,0 +1,74 @@
+package template
+
+import (
+	"go-blog/pkg/util/model"
+	"html"
+	"strings"
+	"time"
+)
+
+// FormatTemplate renders the post as a template that ParseTemplate reads back, so
+// exported posts can be ingested again. Dates are written in RFC 3339 format, unless
+// they are not set.
+func FormatTemplate(post *model.Post) string {
+
+	sb := strings.Builder{}
+	sb.WriteString("<html>\n<head>\n")
+
+	meta := func(name, content string) {
+		sb.WriteString(`	<meta name="` + name + `" content="` + html.EscapeString(content) + `"/>` + "\n")
+	}
+
+	meta("title", post.Title)
+	meta("author", post.Author)
+	if !post.DateCreated.IsZero() {
+		meta("post-date", post.DateCreated.UTC().Format(time.RFC3339))
+	}
+	if !post.DateUpdated.IsZero() {
+		meta("edit-date", post.DateUpdated.UTC().Format(time.RFC3339))
+	}
+
+	if names := categoryNames(post); len(names) > 0 {
+		meta("categories", JoinList(names))
+	}
+	if names := tagNames(post); len(names) > 0 {
+		meta("tags", JoinList(names))
+	}
+
+	sb.WriteString("</head>\n")
+
+	// content is stored with its <body> tag; nothing is written after it, as the
+	// parser would move it into the body
+	content := post.Content
+	if !strings.HasPrefix(strings.TrimSpace(content), "<body") {
+		content = "<body>" + content + "</body>"
+	}
+	sb.WriteString(content)
+	sb.WriteString("</html>")
+
+	return sb.String()
+}
+
+// returns the category names of the post, from the list if set, or from the comma
+// separated string otherwise
+func categoryNames(post *model.Post) (names []string) {
+	if len(post.CategoryList) == 0 {
+		return splitNames(post.Categories)
+	}
+	for i := range post.CategoryList {
+		names = append(names, post.CategoryList[i].Name)
+	}
+	return
+}
+
+// returns the tag names of the post, from the list if set, or from the comma
+// separated string otherwise
+func tagNames(post *model.Post) (names []string) {
+	if len(post.TagList) == 0 {
+		return splitNames(post.Tags)
+	}
+	for i := range post.TagList {
+		names = append(names, post.TagList[i].Name)
+	}
+	return
+}
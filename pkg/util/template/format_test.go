@@ -0,0 +1,31 @@
+package template
+
+import (
+	"go-blog/pkg/util/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatTemplate(t *testing.T) {
+	post := model.Post{
+		Title:        `Rock & "Roll"`,
+		Author:       "John Doe",
+		DateCreated:  time.Date(2020, 4, 15, 12, 9, 57, 0, time.UTC),
+		DateUpdated:  time.Date(2020, 4, 16, 8, 0, 0, 0, time.UTC),
+		Content:      "<body>\n<p>Hello</p>\n</body>",
+		CategoryList: []model.Category{{Name: "Music"}},
+		TagList:      []model.Tag{{Name: "Rock, Pop"}, {Name: `12" vinyl`}, {Name: "jazz"}},
+	}
+
+	got, err := ParseTemplate(FormatTemplate(&post))
+	assert.NoError(t, err)
+	assert.Equal(t, post.Title, got.Title)
+	assert.Equal(t, post.Author, got.Author)
+	assert.True(t, post.DateCreated.Equal(got.DateCreated))
+	assert.True(t, post.DateUpdated.Equal(got.DateUpdated))
+	assert.Equal(t, post.CategoryList, got.CategoryList)
+	assert.Equal(t, post.TagList, got.TagList)
+	assert.Equal(t, post.Content, got.Content)
+}
@@ -0,0 +1,57 @@
+package template
+
+import (
+	"errors"
+	"strings"
+)
+
+// SplitList splits the comma separated values of a categories or tags meta tag.
+// Values may contain commas if they are double quoted (`"Rock, Pop"`) or if
+// commas are escaped with a backslash (`Rock\, Pop`); inside quotes, `\"`
+// stands for a quote. Values are trimmed, and empty values are kept, so
+// callers can report them.
+func SplitList(value string) (items []string, err error) {
+
+	sb := strings.Builder{}
+	quoted, escaped := false, false
+
+	for _, r := range value {
+		switch {
+		case escaped:
+			sb.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+		case r == ',' && !quoted:
+			items = append(items, strings.TrimSpace(sb.String()))
+			sb.Reset()
+		default:
+			sb.WriteRune(r)
+		}
+	}
+
+	if quoted {
+		return nil, errors.New("list has an unterminated quote")
+	}
+	if escaped {
+		sb.WriteRune('\\')
+	}
+
+	items = append(items, strings.TrimSpace(sb.String()))
+	return
+}
+
+// JoinList joins values as the content of a categories or tags meta tag, so that
+// SplitList returns them back; values with commas, quotes or backslashes are quoted
+func JoinList(values []string) string {
+	items := make([]string, len(values))
+	for i, v := range values {
+		if strings.ContainsAny(v, `,"\`) {
+			v = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v) + `"`
+		}
+		items[i] = v
+	}
+	return strings.Join(items, ", ")
+}
@@ -0,0 +1,35 @@
+package template
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitList(t *testing.T) {
+	cases := []struct {
+		name    string
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{name: "Plain values", value: "go, programming,web", want: []string{"go", "programming", "web"}},
+		{name: "Empty values", value: "go,,web", want: []string{"go", "", "web"}},
+		{name: "Quoted comma", value: `"Rock, Pop", Jazz`, want: []string{"Rock, Pop", "Jazz"}},
+		{name: "Escaped comma", value: `Rock\, Pop, Jazz`, want: []string{"Rock, Pop", "Jazz"}},
+		{name: "Escaped quote", value: `"12\" vinyl", "a\\b"`, want: []string{`12" vinyl`, `a\b`}},
+		{name: "Unterminated quote", value: `"Rock, Pop`, wantErr: true},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SplitList(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
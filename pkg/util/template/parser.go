@@ -2,7 +2,6 @@ package template
 
 import (
 	"bytes"
-	"errors"
 	"fmt"
 	"go-blog/pkg/util/model"
 	"io"
@@ -13,35 +12,76 @@ import (
 )
 
 const (
-	// DateFormat is the layout used to parse from template
+	// DateFormat is the legacy layout used to parse dates from template
 	DateFormat = "2006-01-02 15:04:05"
 )
 
+// ParseError is returned when a template can't be parsed; Line and Column are
+// set (1-based) when the position of the problem in the template is known
+type ParseError struct {
+	Message string
+	Line    int
+	Column  int
+}
+
+func (e *ParseError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s (line %d, column %d)", e.Message, e.Line, e.Column)
+	}
+	return e.Message
+}
+
+// creates a parse error pointing to the first occurrence of tagName in the template
+func newParseError(htmlContent, msg, tagName string) *ParseError {
+	line, column := locateTag(htmlContent, tagName, "")
+	return &ParseError{Message: msg, Line: line, Column: column}
+}
+
 // ParseTemplate recives a string with HTML content and parse it to extract
-// blog post metadata
+// blog post metadata; dates without timezone are taken as UTC
 func ParseTemplate(htmlContent string) (post model.Post, err error) {
+	post, _, err = parseTemplate(htmlContent, time.UTC)
+	return
+}
+
+// ParseTemplateInLocation works like ParseTemplate, but dates without timezone are
+// interpreted in loc, unless the template sets its own `timezone` meta tag
+func ParseTemplateInLocation(htmlContent string, loc *time.Location) (post model.Post, err error) {
+	post, _, err = parseTemplate(htmlContent, loc)
+	return
+}
+
+// parses the template and returns the post along with the raw meta tags values
+func parseTemplate(htmlContent string, loc *time.Location) (post model.Post, tags map[string]string, err error) {
 
 	reader := strings.NewReader(htmlContent)
 	doc, errParsing := html.Parse(reader)
 	if errParsing != nil {
-		err = fmt.Errorf("error parsing HTML content: %s", errParsing)
+		err = &ParseError{Message: fmt.Sprintf("error parsing HTML content: %s", errParsing)}
 		return
 	}
 
 	// get head node
 	head := extractHTMLNode(doc, "head")
 	if head == nil {
-		err = errors.New("HTML tag <head> was not found")
+		err = &ParseError{Message: "HTML tag <head> was not found"}
 		return
 	}
 
 	// extract meta tags
-	tags := extractMetaTags(head)
+	tags = extractMetaTags(head)
 	if tags == nil {
-		err = errors.New("no <meta> tags were found")
+		err = newParseError(htmlContent, "no <meta> tags were found", "head")
 		return
 	}
 
+	// the timezone set by the template has precedence over the default one
+	if tz, found := tags["timezone"]; found {
+		if tagLoc, errLoc := LoadLocation(tz); errLoc == nil {
+			loc = tagLoc
+		}
+	}
+
 	for k, v := range tags {
 		switch k {
 		case "title":
@@ -50,14 +90,20 @@ func ParseTemplate(htmlContent string) (post model.Post, err error) {
 			post.Author = v
 		case "categories":
 			post.Categories = v
+			for _, name := range splitNames(v) {
+				post.CategoryList = append(post.CategoryList, model.Category{Name: name})
+			}
 		case "tags":
 			post.Tags = v
+			for _, name := range splitNames(v) {
+				post.TagList = append(post.TagList, model.Tag{Name: name})
+			}
 		case "post-date":
-			if parsedDate, errParse := time.Parse(DateFormat, v); errParse == nil {
+			if parsedDate, errParse := ParseDate(v, loc); errParse == nil {
 				post.DateCreated = parsedDate
 			}
 		case "edit-date":
-			if parsedDate, errParse := time.Parse(DateFormat, v); errParse == nil {
+			if parsedDate, errParse := ParseDate(v, loc); errParse == nil {
 				post.DateUpdated = parsedDate
 			}
 		}
@@ -65,8 +111,8 @@ func ParseTemplate(htmlContent string) (post model.Post, err error) {
 
 	// get body node
 	body := extractHTMLNode(doc, "body")
-	if head == nil {
-		err = errors.New("HTML tag <body> was not found")
+	if body == nil {
+		err = &ParseError{Message: "HTML tag <body> was not found"}
 		return
 	}
 
@@ -76,6 +122,18 @@ func ParseTemplate(htmlContent string) (post model.Post, err error) {
 	return
 }
 
+// returns the non empty values of a list meta tag; lists that can't be split
+// are reported by the validator
+func splitNames(value string) (names []string) {
+	items, _ := SplitList(value)
+	for _, name := range items {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return
+}
+
 // extract meta tags values from head and put them into a map
 func extractMetaTags(head *html.Node) (keys map[string]string) {
 	for child := head.FirstChild; child != nil; child = child.NextSibling {
@@ -129,3 +187,76 @@ func nodeToString(node *html.Node) string {
 	html.Render(w, node)
 	return buf.String()
 }
+
+// finds the line and column (1-based) of the first start tag named tagName; if
+// metaName is set, only tags with that value in the "name" attribute are matched.
+// Zero values are returned if the tag is not in the raw content
+func locateTag(htmlContent, tagName, metaName string) (line, column int) {
+	z := html.NewTokenizer(strings.NewReader(htmlContent))
+	offset := 0
+
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return
+		}
+
+		if tt == html.StartTagToken || tt == html.SelfClosingTagToken {
+			if token := z.Token(); token.Data == tagName && (metaName == "" || attrValue(token, "name") == metaName) {
+				return offsetToPosition(htmlContent, offset)
+			}
+		}
+
+		offset += len(z.Raw())
+	}
+}
+
+// returns the value of the indicated attribute of the token
+func attrValue(token html.Token, key string) string {
+	for i := range token.Attr {
+		if token.Attr[i].Key == key {
+			return token.Attr[i].Val
+		}
+	}
+	return ""
+}
+
+// converts a byte offset into a line and column (1-based)
+func offsetToPosition(content string, offset int) (line, column int) {
+	if offset > len(content) {
+		offset = len(content)
+	}
+
+	before := content[:offset]
+	line = strings.Count(before, "\n") + 1
+	column = offset - strings.LastIndex(before, "\n")
+
+	return
+}
+
+// returns the text contained in the template body
+func extractText(htmlContent string) string {
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return ""
+	}
+
+	body := extractHTMLNode(doc, "body")
+	if body == nil {
+		return ""
+	}
+
+	var sb strings.Builder
+	var crawler func(*html.Node)
+	crawler = func(node *html.Node) {
+		if node.Type == html.TextNode {
+			sb.WriteString(node.Data)
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			crawler(child)
+		}
+	}
+
+	crawler(body)
+	return sb.String()
+}
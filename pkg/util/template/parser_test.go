@@ -29,8 +29,27 @@ func TestParseTemplate(t *testing.T) {
 	assert.Equal(t, "John Doe", post.Author)
 	assert.Equal(t, "Go Programming", post.Categories)
 	assert.Equal(t, "go, programming, web", post.Tags)
+	assert.Len(t, post.TagList, 3)
 	assert.NotEmpty(t, post.Content)
 
 	t.Logf("%+v", post)
 
 }
+
+func TestParseTemplateErrorPosition(t *testing.T) {
+
+	htmlExample := `<html>
+  <head>
+	<title>No metadata</title>
+  </head>
+<body></body>
+</html>`
+
+	_, err := ParseTemplate(htmlExample)
+	assert.Error(t, err)
+
+	errParse, ok := err.(*ParseError)
+	assert.True(t, ok)
+	assert.Equal(t, 2, errParse.Line)
+	assert.Equal(t, 3, errParse.Column)
+}
@@ -1,143 +1,447 @@
 package template
 
 import (
+	"context"
 	"fmt"
 	"go-blog/pkg/util/log"
+	"go-blog/pkg/util/metrics"
 	"go-blog/pkg/util/model"
+	"go-blog/pkg/util/tracing"
 	"io"
 	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
-	"strings"
+	"sync"
 	"time"
 
-	"github.com/jinzhu/gorm"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-// NewProcessor creates a new instance of the template processor
-func NewProcessor(database *gorm.DB, logger *log.Log, processedOKLocation string, processedErrorLocation string) *Processor {
+// Store holds the functions used to save the posts extracted from templates; PreviewPost
+// must work like CreatePost, with no changes saved
+type Store interface {
+	CreatePost(ctx context.Context, post *model.Post) error
+	PreviewPost(ctx context.Context, post *model.Post) error
+}
+
+// NewProcessor creates a new instance of the template processor; in dry run mode, templates
+// are processed with no changes to the database or the template files
+func NewProcessor(store Store, logger *log.Log, validator *Validator, processedOKLocation string, processedErrorLocation string, dryRun bool) *Processor {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &Processor{
-		database:               database,
+		store:                  store,
 		logger:                 logger,
+		validator:              validator,
 		processedOKLocation:    processedOKLocation,
 		processedErrorLocation: processedErrorLocation,
+		dryRun:                 dryRun,
+		previewed:              make(map[string]time.Time),
+		unmoved:                make(map[string]unmovedTemplate),
+		ctx:                    ctx,
+		cancel:                 cancel,
 	}
 }
 
+// MoveError is returned when the post of a template was saved, but the template couldn't
+// be moved to the OK folder; the template must not be processed again as a new one
+type MoveError struct {
+	Err error
+}
+
+func (e *MoveError) Error() string {
+	return "post saved, but the template couldn't be moved: " + e.Err.Error()
+}
+
+// template left in the templates folder because it couldn't be moved once processed
+type unmovedTemplate struct {
+	modTime  time.Time
+	rejected bool  // the template goes to the error folder; otherwise, its post was saved
+	err      error // error that made the template fail, if rejected
+	report   *ErrorReport
+}
+
 // Processor is used to process template files from the templates folder.
 // Once processed, the files are moved to OK or Error folders, just for future references.
 type Processor struct {
-	logger                 *log.Log
+	logger *log.Log
+	store  Store
+	dryRun bool
+
+	// validator and locations may be changed while templates are processed
+	mu                     sync.Mutex
+	validator              *Validator
 	processedOKLocation    string
 	processedErrorLocation string
-	database               *gorm.DB
+
+	// templates are not moved in dry run mode, so the files already processed are kept,
+	// with their modification date, to process them again only if they change
+	previewed map[string]time.Time
+
+	// templates that couldn't be moved once processed; while they don't change, they're
+	// only moved again, so posts are not saved twice and rejections are not repeated
+	unmoved map[string]unmovedTemplate
+
+	// templates sent by the watcher are processed with ctx, which is canceled if they
+	// don't end in time once the processor is stopped
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stopping bool
+	inFlight sync.WaitGroup
 }
 
-// ProcessTemplate process a template file, by
-func (p *Processor) ProcessTemplate(filePath string) {
+// Reconfigure changes the validator and the folders where templates are moved; templates
+// being processed may use the previous ones
+func (p *Processor) Reconfigure(validator *Validator, processedOKLocation string, processedErrorLocation string) {
+	p.mu.Lock()
+	p.validator = validator
+	p.processedOKLocation = processedOKLocation
+	p.processedErrorLocation = processedErrorLocation
+	p.mu.Unlock()
+}
+
+// returns the validator in use
+func (p *Processor) currentValidator() *Validator {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 
-	p.logger.Info("processing file "+filePath, nil)
+	return p.validator
+}
+
+// ProcessTemplate process a template file, by reading and parsing its content and then
+// saving the post in the database. Templates that fail are moved to the error folder,
+// together with a JSON report describing the failure. The messages logged, and the report,
+// include ingestionID.
+func (p *Processor) ProcessTemplate(ingestionID, filePath string) {
+	ctx := tracing.WithIngestionID(p.ctx, ingestionID)
+
+	if !p.begin() {
+		p.logger.WithContext(ctx).Info("processor is stopping; template left for the next start", map[string]interface{}{"file": filePath})
+		return
+	}
+	defer p.inFlight.Done()
+
+	p.Process(ctx, filePath)
+}
+
+// Stop stops accepting templates from ProcessTemplate, and waits for the ones being
+// processed to end. If ctx is done first, they are canceled: posts being saved are
+// rolled back, and their templates are left to be processed on the next start.
+func (p *Processor) Stop(ctx context.Context) error {
+	p.mu.Lock()
+	p.stopping = true
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.inFlight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		p.cancel()
+		return ctx.Err()
+	}
+}
+
+// registers a template being processed; returns false if the processor is stopping
+func (p *Processor) begin() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.stopping {
+		return false
+	}
+	p.inFlight.Add(1)
+	return true
+}
+
+// Process works like ProcessTemplate, but it also returns the error that made the template
+// fail; saving the post is canceled when ctx is done. The ingestion ID is taken from ctx;
+// if it has none, the ID of the request in ctx is used, or a new one otherwise.
+func (p *Processor) Process(ctx context.Context, filePath string) (err error) {
+
+	if tracing.IngestionID(ctx) == "" {
+		ingestionID := tracing.RequestID(ctx)
+		if ingestionID == "" {
+			ingestionID = tracing.NewID()
+		}
+		ctx = tracing.WithIngestionID(ctx, ingestionID)
+	}
+	logger := p.logger.WithContext(ctx)
+
+	ctx, span := tracing.Start(ctx, "template.process", attribute.String("template.file", filePath), attribute.Bool("dry_run", p.dryRun))
+	defer func() { tracing.End(span, err) }()
+
+	if p.dryRun {
+		return p.preview(ctx, filePath)
+	}
+
+	start := time.Now()
+	defer func() {
+		metrics.TemplateProcessingDuration.Observe(time.Since(start).Seconds())
+	}()
+
+	if unmoved, found := p.unmovedTemplate(filePath); found {
+		return p.moveAgain(ctx, filePath, unmoved)
+	}
+
+	logger.Info("processing file "+filePath, nil)
 
 	// read file content
+	_, readSpan := tracing.Start(ctx, "template.read")
 	data, errRead := ioutil.ReadFile(filePath)
+	tracing.End(readSpan, errRead)
 	if errRead != nil {
-		p.logger.Error("error reading template content", errRead, map[string]interface{}{"file": filePath})
-		return
+		logger.Error("error reading template content", errRead, map[string]interface{}{"file": filePath})
+		p.reject(ctx, filePath, StageRead, errRead, nil)
+		return errRead
 	}
 
-	// parse
-	post, errParse := ParseTemplate(string(data))
-	if errParse != nil {
-		p.logger.Error("error parsing template", errParse, map[string]interface{}{"file": filePath})
+	// parse and validate; original file name is saved for reference
+	_, parseSpan := tracing.Start(ctx, "template.parse")
+	post, diags, errValidate := p.currentValidator().Validate(path.Base(filePath), string(data))
+	tracing.End(parseSpan, errValidate)
+	if errValidate != nil {
+		if _, ok := errValidate.(*ValidationError); ok {
+			logger.Error("template is not valid", errValidate, map[string]interface{}{"file": filePath})
+			p.reject(ctx, filePath, StageValidate, errValidate, &post)
+		} else {
+			// no metadata is extracted from templates that can't be parsed
+			logger.Error("error parsing template", errValidate, map[string]interface{}{"file": filePath})
+			p.reject(ctx, filePath, StageParse, errValidate, nil)
+		}
+		return errValidate
+	}
 
-		// move file to error folder
-		if errMove := p.moveFile(filePath, true); errMove != nil {
-			p.logger.Error("error moving template", errMove, map[string]interface{}{"file": filePath})
+	for i := range diags {
+		logger.Warn("template validation warning", map[string]interface{}{"file": filePath, "field": diags[i].Field, "message": diags[i].Message})
+	}
+
+	setDates(&post)
+
+	// save in the database
+	saveCtx, saveSpan := tracing.Start(ctx, "template.save")
+	errSave := p.store.CreatePost(saveCtx, &post)
+	tracing.End(saveSpan, errSave)
+	if errSave != nil {
+		// canceled saves are rolled back, and the template is kept to be processed again
+		if ctx.Err() != nil {
+			logger.Warn("template processing canceled; it's left for the next start", map[string]interface{}{"file": filePath})
+			return errSave
 		}
+		logger.Error("error saving template to the database", errSave, map[string]interface{}{"file": filePath})
+		p.reject(ctx, filePath, StageSave, errSave, &post)
+		return errSave
+	}
+
+	// mark file to processed OK
+	_, moveSpan := tracing.Start(ctx, "template.move")
+	_, errMove := p.moveFile(filePath, false)
+	tracing.End(moveSpan, errMove)
+	if errMove != nil {
+		// the post is kept, and the template is not rejected, so it's not saved again
+		metrics.TemplatesFailed.WithLabelValues(StageMove).Inc()
+		logger.Error("post saved, but error moving template", errMove, map[string]interface{}{"file": filePath})
+
+		p.keepUnmoved(ctx, filePath, unmovedTemplate{report: newErrorReport(path.Base(filePath), StageMove, errMove, &post)})
+		return &MoveError{Err: errMove}
+	}
+
+	metrics.TemplatesProcessed.Inc()
+	logger.Info("file "+filePath+" processed OK", nil)
+	return
+}
 
+// keeps track of a template that couldn't be moved, so it's only moved again the next
+// time it's processed, unless it changes; its error report is written next to it
+func (p *Processor) keepUnmoved(ctx context.Context, filePath string, unmoved unmovedTemplate) {
+
+	logger := p.logger.WithContext(ctx)
+
+	unmoved.report.IngestionID = tracing.IngestionID(ctx)
+	if errWrite := unmoved.report.write(filePath + ErrorReportExtension); errWrite != nil {
+		logger.Error("error writing template error report", errWrite, map[string]interface{}{"file": filePath})
+	}
+
+	fi, err := os.Stat(filePath)
+	if err != nil {
 		return
 	}
+	unmoved.modTime = fi.ModTime()
 
-	// save original file name, for reference
-	post.OriginalFileName = path.Base(filePath)
+	p.mu.Lock()
+	p.unmoved[filePath] = unmoved
+	p.mu.Unlock()
+}
 
-	// set date created and updated if wasn't set in the template
-	if post.DateCreated.Year() == 1 {
-		post.DateCreated = time.Now()
+// returns the template if it couldn't be moved once processed, and it didn't change
+// since then
+func (p *Processor) unmovedTemplate(filePath string) (unmoved unmovedTemplate, found bool) {
+	fi, err := os.Stat(filePath)
+	if err != nil {
+		return
 	}
-	if post.DateUpdated.Year() == 1 {
-		post.DateUpdated = time.Now()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	unmoved, found = p.unmoved[filePath]
+	if found && !unmoved.modTime.Equal(fi.ModTime()) {
+		delete(p.unmoved, filePath)
+		return unmovedTemplate{}, false
 	}
+	return
+}
 
-	// save in the database
-	if errSave := p.savePost(&post); errSave != nil {
-		p.logger.Error("error saving template to the database", errSave, map[string]interface{}{"file": filePath})
+// moves again a template that couldn't be moved once processed, with no new post or
+// rejection; failures are only logged for debugging, as they were already reported
+func (p *Processor) moveAgain(ctx context.Context, filePath string, unmoved unmovedTemplate) (err error) {
+
+	logger := p.logger.WithContext(ctx)
 
-		// move file to error folder
-		if errMove := p.moveFile(filePath, true); errMove != nil {
-			p.logger.Error("error moving template", errMove, map[string]interface{}{"file": filePath})
+	_, moveSpan := tracing.Start(ctx, "template.move")
+	destFile, errMove := p.moveFile(filePath, unmoved.rejected)
+	tracing.End(moveSpan, errMove)
+	if errMove != nil {
+		logger.Debug("template still can't be moved", map[string]interface{}{"file": filePath, "error": errMove.Error()})
+		if unmoved.rejected {
+			return unmoved.err
 		}
+		return &MoveError{Err: errMove}
+	}
 
-		return
+	p.mu.Lock()
+	delete(p.unmoved, filePath)
+	p.mu.Unlock()
+
+	// the report written next to the template is moved along with it, if rejected
+	if errRemove := os.Remove(filePath + ErrorReportExtension); errRemove != nil && !os.IsNotExist(errRemove) {
+		logger.Error("error removing template error report", errRemove, map[string]interface{}{"file": filePath})
 	}
 
-	// mark file to processed OK
-	if errMove := p.moveFile(filePath, false); errMove != nil {
-		p.logger.Error("error moving template", errMove, map[string]interface{}{"file": filePath})
-		return
+	if unmoved.rejected {
+		if errWrite := unmoved.report.write(destFile + ErrorReportExtension); errWrite != nil {
+			logger.Error("error writing template error report", errWrite, map[string]interface{}{"file": destFile})
+		}
+		logger.Info("file "+filePath+" moved to the error folder", nil)
+		return unmoved.err
 	}
 
-	p.logger.Info("file "+filePath+" processed OK", nil)
+	metrics.TemplatesProcessed.Inc()
+	logger.Info("file "+filePath+" processed OK", nil)
+	return
 }
 
-func (p *Processor) savePost(post *model.Post) (err error) {
+// processes the template in dry run mode: the template is parsed, validated and saved
+// in a transaction that is rolled back, and the outcome is logged; the template is left
+// in place, and it's not processed again unless it changes
+func (p *Processor) preview(ctx context.Context, filePath string) (err error) {
 
-	trx := p.database.Begin()
+	logger := p.logger.WithContext(ctx)
 
-	// save post
-	if err = trx.Create(post).Error; err != nil {
+	fi, err := os.Stat(filePath)
+	if err != nil {
+		logger.Error("error reading template", err, map[string]interface{}{"file": filePath, "dry_run": true})
 		return
 	}
 
-	// save categories
-	if len(post.Categories) > 0 {
-		categories := strings.Split(post.Categories, ",")
-		for i := range categories {
-			if err = trx.Create(&model.PostCategory{IDPost: post.ID, Name: strings.Trim(categories[i], " ")}).Error; err != nil {
-				trx.Rollback()
-				return
-			}
-		}
+	p.mu.Lock()
+	if modTime, found := p.previewed[filePath]; found && modTime.Equal(fi.ModTime()) {
+		p.mu.Unlock()
+		return
 	}
+	p.previewed[filePath] = fi.ModTime()
+	p.mu.Unlock()
 
-	// save tags
-	if len(post.Tags) > 0 {
-		tags := strings.Split(post.Tags, ",")
-		for i := range tags {
-			if err = trx.Create(&model.PostTag{IDPost: post.ID, Name: strings.Trim(tags[i], " ")}).Error; err != nil {
-				trx.Rollback()
-				return
-			}
-		}
+	logger.Info("processing file "+filePath+" in dry run mode", nil)
+
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		logger.Error("error reading template content", err, map[string]interface{}{"file": filePath, "dry_run": true})
+		return
+	}
+
+	post, diags, err := p.currentValidator().Validate(path.Base(filePath), string(data))
+	if err != nil {
+		logger.Error("template would be rejected", err, map[string]interface{}{"file": filePath, "dry_run": true})
+		return
+	}
+
+	for i := range diags {
+		logger.Warn("template validation warning", map[string]interface{}{"file": filePath, "field": diags[i].Field, "message": diags[i].Message, "dry_run": true})
 	}
 
-	trx.Commit()
+	setDates(&post)
+
+	if err = p.store.PreviewPost(ctx, &post); err != nil {
+		logger.Error("template would fail to be saved", err, map[string]interface{}{"file": filePath, "dry_run": true})
+		return
+	}
+
+	logger.Info("template would be saved", map[string]interface{}{
+		"file":       filePath,
+		"id":         post.ID,
+		"title":      post.Title,
+		"categories": post.Categories,
+		"tags":       post.Tags,
+		"dry_run":    true,
+	})
+
 	return
 }
 
-func (p *Processor) moveFile(srcFile string, failed bool) (err error) {
+// sets date created and updated if they weren't set in the template; dates are stored in UTC
+func setDates(post *model.Post) {
+	if post.DateCreated.Year() == 1 {
+		post.DateCreated = time.Now().UTC()
+	}
+	if post.DateUpdated.Year() == 1 {
+		post.DateUpdated = time.Now().UTC()
+	}
+}
+
+// moves the template to the error folder and writes the error report next to it, with
+// the ingestion ID held by ctx. If the template can't be moved, it's left in place with
+// the report next to it, and it's moved again the next time it's processed.
+func (p *Processor) reject(ctx context.Context, filePath, stage string, err error, post *model.Post) {
+
+	metrics.TemplatesFailed.WithLabelValues(stage).Inc()
+
+	logger := p.logger.WithContext(ctx)
+	report := newErrorReport(path.Base(filePath), stage, err, post)
+
+	destFile, errMove := p.moveFile(filePath, true)
+	if errMove != nil {
+		logger.Error("error moving template to the error folder", errMove, map[string]interface{}{"file": filePath})
+		p.keepUnmoved(ctx, filePath, unmovedTemplate{rejected: true, err: err, report: report})
+		return
+	}
+
+	report.IngestionID = tracing.IngestionID(ctx)
+	if errWrite := report.write(destFile + ErrorReportExtension); errWrite != nil {
+		logger.Error("error writing template error report", errWrite, map[string]interface{}{"file": destFile})
+	}
+}
+
+// moves the template to the OK or error folder and returns its new location
+func (p *Processor) moveFile(srcFile string, failed bool) (destFile string, err error) {
 
 	// move to OK or error?
+	p.mu.Lock()
 	destPath := p.processedOKLocation
 	if failed {
 		destPath = p.processedErrorLocation
 	}
+	p.mu.Unlock()
 
 	// prefix new file name with timestamp
 	srcFileName := filepath.Base(srcFile)
 	newFileName := fmt.Sprintf("%v_%s", time.Now().Unix(), srcFileName)
-	destFile := path.Join(destPath, newFileName)
+	destFile = path.Join(destPath, newFileName)
 
 	// copy file to destination
 	if err = copyFile(srcFile, destFile); err != nil {
@@ -0,0 +1,173 @@
+package template
+
+import (
+	"context"
+	"encoding/json"
+	"go-blog/pkg/util/log"
+	"go-blog/pkg/util/model"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const validTemplate = `<head>
+	<meta name="title" content="My First Blog Post"/>
+	<meta name="author" content="John Doe"/>
+</head>
+<body><p>Hello</p></body>`
+
+type store struct {
+	saved int
+}
+
+func (s *store) CreatePost(ctx context.Context, post *model.Post) error {
+	s.saved++
+	return nil
+}
+
+func (s *store) PreviewPost(ctx context.Context, post *model.Post) error {
+	return nil
+}
+
+// creates the templates folders; the OK folder is not created, so templates can't be moved
+func newTestFolders(t *testing.T) (base, ok, failed string) {
+	dir, err := ioutil.TempDir("", "processor")
+	assert.NoError(t, err)
+
+	base, ok, failed = filepath.Join(dir, "templates"), filepath.Join(dir, "ok"), filepath.Join(dir, "error")
+	assert.NoError(t, os.Mkdir(base, 0755))
+	assert.NoError(t, os.Mkdir(failed, 0755))
+	return
+}
+
+func TestProcessMoveError(t *testing.T) {
+	base, ok, failed := newTestFolders(t)
+	defer os.RemoveAll(filepath.Dir(base))
+
+	file := filepath.Join(base, "post.tpl")
+	assert.NoError(t, ioutil.WriteFile(file, []byte(validTemplate), 0644))
+
+	s := &store{}
+	p := NewProcessor(s, log.New(), NewValidator(false, time.UTC), ok, failed, false)
+
+	// the post is saved, and the template is kept in place instead of being rejected
+	err := p.Process(context.Background(), file)
+	assert.IsType(t, &MoveError{}, err)
+	assert.Equal(t, 1, s.saved)
+	assert.FileExists(t, file)
+	rejected, _ := ioutil.ReadDir(failed)
+	assert.Empty(t, rejected)
+	assert.Equal(t, StageMove, readReport(t, file+ErrorReportExtension).Stage)
+
+	// next time, the template is only moved
+	err = p.Process(context.Background(), file)
+	assert.IsType(t, &MoveError{}, err)
+	assert.NoError(t, os.Mkdir(ok, 0755))
+	assert.NoError(t, p.Process(context.Background(), file))
+	assert.Equal(t, 1, s.saved)
+	assert.NoFileExists(t, file)
+	assert.NoFileExists(t, file+ErrorReportExtension)
+	moved, _ := ioutil.ReadDir(ok)
+	assert.Len(t, moved, 1)
+
+	// a template that changes is saved again
+	assert.NoError(t, os.RemoveAll(ok))
+	assert.NoError(t, ioutil.WriteFile(file, []byte(validTemplate), 0644))
+	assert.IsType(t, &MoveError{}, p.Process(context.Background(), file))
+	future := time.Now().Add(time.Hour)
+	assert.NoError(t, os.Chtimes(file, future, future))
+	assert.IsType(t, &MoveError{}, p.Process(context.Background(), file))
+	assert.Equal(t, 3, s.saved)
+}
+
+func TestRejectMoveError(t *testing.T) {
+	base, ok, failed := newTestFolders(t)
+	defer os.RemoveAll(filepath.Dir(base))
+	assert.NoError(t, os.Remove(failed))
+
+	file := filepath.Join(base, "post.tpl")
+	assert.NoError(t, ioutil.WriteFile(file, []byte(`<head><meta name="title" content="Post"/></head><body></body>`), 0644))
+	fi, err := os.Stat(file)
+	assert.NoError(t, err)
+
+	s := &store{}
+	p := NewProcessor(s, log.New(), NewValidator(false, time.UTC), ok, failed, false)
+
+	// the template is left in place, with the report next to it
+	errProcess := p.Process(context.Background(), file)
+	assert.IsType(t, &ValidationError{}, errProcess)
+	assert.FileExists(t, file)
+	assert.Equal(t, StageValidate, readReport(t, file+ErrorReportExtension).Stage)
+
+	// while it doesn't change, it's not processed again
+	assert.NoError(t, ioutil.WriteFile(file, []byte(validTemplate), 0644))
+	assert.NoError(t, os.Chtimes(file, fi.ModTime(), fi.ModTime()))
+	assert.Equal(t, errProcess, p.Process(context.Background(), file))
+	assert.Equal(t, 0, s.saved)
+
+	// once the error folder can be reached, it's moved along with its report
+	assert.NoError(t, os.Mkdir(failed, 0755))
+	assert.Equal(t, errProcess, p.Process(context.Background(), file))
+	assert.NoFileExists(t, file)
+	assert.NoFileExists(t, file+ErrorReportExtension)
+	reports, _ := filepath.Glob(filepath.Join(failed, "*"+ErrorReportExtension))
+	if assert.Len(t, reports, 1) {
+		assert.Equal(t, StageValidate, readReport(t, reports[0]).Stage)
+	}
+	assert.Equal(t, 0, s.saved)
+}
+
+func readReport(t *testing.T, file string) (report ErrorReport) {
+	data, err := ioutil.ReadFile(file)
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(data, &report))
+	return
+}
+
+func TestProcessErrorReport(t *testing.T) {
+	cases := []struct {
+		name         string
+		template     string
+		wantStage    string
+		wantMetadata map[string]string
+	}{
+		{
+			name:      "Parse error",
+			template:  `<head></head><body><p>Hello</p></body>`,
+			wantStage: StageParse,
+		},
+		{
+			name: "Validation error",
+			template: `<head>
+	<meta name="title" content="My First Blog Post"/>
+</head>
+<body><p>Hello</p></body>`,
+			wantStage:    StageValidate,
+			wantMetadata: map[string]string{"title": "My First Blog Post"},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			base, ok, failed := newTestFolders(t)
+			defer os.RemoveAll(filepath.Dir(base))
+
+			file := filepath.Join(base, "post.tpl")
+			assert.NoError(t, ioutil.WriteFile(file, []byte(tt.template), 0644))
+
+			p := NewProcessor(&store{}, log.New(), NewValidator(false, time.UTC), ok, failed, false)
+			assert.Error(t, p.Process(context.Background(), file))
+
+			reports, _ := filepath.Glob(filepath.Join(failed, "*"+ErrorReportExtension))
+			if assert.Len(t, reports, 1) {
+				report := readReport(t, reports[0])
+				assert.Equal(t, tt.wantStage, report.Stage)
+				assert.Equal(t, tt.wantMetadata, report.Metadata)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package template
+
+import (
+	"encoding/json"
+	"go-blog/pkg/util/model"
+	"io/ioutil"
+	"time"
+)
+
+// Processing stages, used to report where a template failed
+const (
+	StageRead     = "read"
+	StageParse    = "parse"
+	StageValidate = "validate"
+	StageSave     = "save"
+	StageMove     = "move"
+)
+
+const (
+	// ErrorReportExtension is appended to the name of a rejected template to name its sidecar
+	ErrorReportExtension = ".error.json"
+)
+
+// ErrorReport describes why a template was moved to the error folder. It's written
+// as a JSON sidecar next to the rejected file, so authors can find out what went wrong
+type ErrorReport struct {
+	File        string            `json:"file"`
+	IngestionID string            `json:"ingestion_id,omitempty"`
+	Stage       string            `json:"stage"`
+	Error       string            `json:"error"`
+	Line        int               `json:"line,omitempty"`
+	Column      int               `json:"column,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	Diagnostics Diagnostics       `json:"diagnostics,omitempty"`
+	Date        time.Time         `json:"date"`
+}
+
+// creates a report for the failed stage, including the position of the error when
+// the parser provided one and the metadata of post, if the template was parsed
+func newErrorReport(fileName, stage string, err error, post *model.Post) *ErrorReport {
+	report := &ErrorReport{
+		File:  fileName,
+		Stage: stage,
+		Error: err.Error(),
+		Date:  time.Now(),
+	}
+
+	switch e := err.(type) {
+	case *ParseError:
+		report.Error = e.Message
+		report.Line = e.Line
+		report.Column = e.Column
+	case *ValidationError:
+		report.Diagnostics = e.Diagnostics
+	}
+
+	if post != nil {
+		report.Metadata = postMetadata(post)
+	}
+
+	return report
+}
+
+// writes the report as indented JSON to the indicated file
+func (r *ErrorReport) write(filePath string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filePath, data, 0644)
+}
+
+// returns the post metadata using the same names as the template meta tags;
+// values that were not set are left out
+func postMetadata(post *model.Post) (m map[string]string) {
+	m = make(map[string]string)
+
+	add := func(k, v string) {
+		if v != "" {
+			m[k] = v
+		}
+	}
+
+	add("title", post.Title)
+	add("author", post.Author)
+	add("categories", post.Categories)
+	add("tags", post.Tags)
+
+	if !post.DateCreated.IsZero() {
+		m["post-date"] = post.DateCreated.Format(time.RFC3339)
+	}
+	if !post.DateUpdated.IsZero() {
+		m["edit-date"] = post.DateUpdated.Format(time.RFC3339)
+	}
+
+	if len(m) == 0 {
+		return nil
+	}
+
+	return
+}
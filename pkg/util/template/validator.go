@@ -0,0 +1,188 @@
+package template
+
+import (
+	"fmt"
+	"go-blog/pkg/util/model"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Diagnostic severities
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+const (
+	// MaxFieldLength is the max length allowed for text fields, based on the
+	// varchar(128) columns defined in model.Post
+	MaxFieldLength = 128
+)
+
+// Diagnostic describes a problem found while validating a template
+type Diagnostic struct {
+	Field    string `json:"field"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+}
+
+// Diagnostics is the list of problems found while validating a template
+type Diagnostics []Diagnostic
+
+// HasErrors returns true if at least one diagnostic has error severity
+func (d Diagnostics) HasErrors() bool {
+	for i := range d {
+		if d[i].Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the diagnostics as a single line, with the field of each one of them
+func (d Diagnostics) String() string {
+	msgs := make([]string, len(d))
+	for i := range d {
+		msgs[i] = d[i].Field + ": " + d[i].Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Failed returns true if the diagnostics must reject the template; in strict
+// mode warnings also reject it
+func (d Diagnostics) Failed(strict bool) bool {
+	if strict {
+		return len(d) > 0
+	}
+	return d.HasErrors()
+}
+
+// ValidationError is returned when a template doesn't pass the validation rules
+type ValidationError struct {
+	Diagnostics Diagnostics
+}
+
+func (e *ValidationError) Error() string {
+	return "template validation failed: " + e.Diagnostics.String()
+}
+
+// NewValidator creates a new template validator; dates without timezone are
+// interpreted in loc, unless templates set their own timezone
+func NewValidator(strict bool, loc *time.Location) *Validator {
+	if loc == nil {
+		loc = time.UTC
+	}
+	return &Validator{Strict: strict, Location: loc}
+}
+
+// Validator checks that templates contain the metadata required to store a post.
+// If Strict is set, templates with warnings are rejected too.
+type Validator struct {
+	Strict   bool
+	Location *time.Location
+}
+
+// Validate parses the template and checks its metadata. The parsed post is returned
+// together with the diagnostics found; err is set if the template is rejected, either
+// because it can't be parsed (*ParseError) or it doesn't pass validation (*ValidationError).
+// If fileName is set, it's stored in the post and validated too.
+func (v *Validator) Validate(fileName, htmlContent string) (post model.Post, diags Diagnostics, err error) {
+
+	post, tags, err := parseTemplate(htmlContent, v.Location)
+	if err != nil {
+		return
+	}
+
+	add := func(field, severity, msg string) {
+		line, column := locateTag(htmlContent, "meta", field)
+		diags = append(diags, Diagnostic{Field: field, Severity: severity, Message: msg, Line: line, Column: column})
+	}
+
+	// required fields
+	for _, field := range []string{"title", "author"} {
+		value := tags[field]
+		if strings.TrimSpace(value) == "" {
+			add(field, SeverityError, "required field is missing")
+		} else if utf8.RuneCountInString(value) > MaxFieldLength {
+			add(field, SeverityError, fmt.Sprintf("value is longer than %d characters", MaxFieldLength))
+		}
+	}
+
+	// dates
+	loc := v.Location
+	if tz, found := tags["timezone"]; found {
+		if tagLoc, errLoc := LoadLocation(tz); errLoc != nil {
+			add("timezone", SeverityError, fmt.Sprintf("unknown timezone '%s'", tz))
+		} else {
+			loc = tagLoc
+		}
+	}
+
+	for _, field := range []string{"post-date", "edit-date"} {
+		value, found := tags[field]
+		if !found {
+			add(field, SeverityWarning, "date is not set; processing date will be used")
+		} else if _, errParse := ParseDate(value, loc); errParse != nil {
+			add(field, SeverityError, errParse.Error())
+		}
+	}
+
+	if !post.DateCreated.IsZero() && !post.DateUpdated.IsZero() && post.DateUpdated.Before(post.DateCreated) {
+		add("edit-date", SeverityWarning, "edit date is before post date")
+	}
+
+	// categories and tags
+	for _, field := range []string{"categories", "tags"} {
+		value, found := tags[field]
+		if !found {
+			continue
+		}
+
+		names, errSplit := SplitList(value)
+		if errSplit != nil {
+			add(field, SeverityError, errSplit.Error())
+			continue
+		}
+
+		for _, name := range names {
+			if name == "" {
+				add(field, SeverityWarning, "list contains empty values")
+			} else if utf8.RuneCountInString(name) > MaxFieldLength {
+				add(field, SeverityError, fmt.Sprintf("value '%s' is longer than %d characters", name, MaxFieldLength))
+			}
+		}
+	}
+
+	// file name
+	if fileName != "" {
+		post.OriginalFileName = fileName
+		if utf8.RuneCountInString(fileName) > MaxFieldLength {
+			diags = append(diags, Diagnostic{
+				Field:    "filename",
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("file name is longer than %d characters", MaxFieldLength),
+			})
+		}
+	}
+
+	// content
+	if strings.TrimSpace(extractText(htmlContent)) == "" {
+		line, column := locateTag(htmlContent, "body", "")
+		diags = append(diags, Diagnostic{
+			Field:    "body",
+			Severity: SeverityWarning,
+			Message:  "post has no content",
+			Line:     line,
+			Column:   column,
+		})
+	}
+
+	if diags.Failed(v.Strict) {
+		err = &ValidationError{Diagnostics: diags}
+	}
+
+	return
+}
@@ -0,0 +1,115 @@
+package template
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		name       string
+		strict     bool
+		template   string
+		wantErr    bool
+		wantFields []string
+	}{
+		{
+			name: "Valid template",
+			template: `<head>
+	<meta name="title" content="My First Blog Post"/>
+	<meta name="author" content="John Doe"/>
+	<meta name="post-date" content="2020-04-15 12:09:57"/>
+	<meta name="edit-date" content="2020-04-15 12:19:05"/>
+</head>
+<body><p>Hello</p></body>`,
+		},
+		{
+			name: "Missing required fields",
+			template: `<head>
+	<meta name="post-date" content="2020-04-15 12:09:57"/>
+	<meta name="edit-date" content="2020-04-15 12:19:05"/>
+</head>
+<body><p>Hello</p></body>`,
+			wantErr:    true,
+			wantFields: []string{"title", "author"},
+		},
+		{
+			name: "Invalid date",
+			template: `<head>
+	<meta name="title" content="My First Blog Post"/>
+	<meta name="author" content="John Doe"/>
+	<meta name="post-date" content="15/04/2020"/>
+	<meta name="edit-date" content="2020-04-15 12:19:05"/>
+</head>
+<body><p>Hello</p></body>`,
+			wantErr:    true,
+			wantFields: []string{"post-date"},
+		},
+		{
+			name: "Warnings allowed",
+			template: `<head>
+	<meta name="title" content="My First Blog Post"/>
+	<meta name="author" content="John Doe"/>
+	<meta name="tags" content="go,,web"/>
+</head>
+<body><p>Hello</p></body>`,
+			wantFields: []string{"post-date", "edit-date", "tags"},
+		},
+		{
+			name: "Unterminated quote in list",
+			template: `<head>
+	<meta name="title" content="My First Blog Post"/>
+	<meta name="author" content="John Doe"/>
+	<meta name="post-date" content="2020-04-15 12:09:57"/>
+	<meta name="edit-date" content="2020-04-15 12:19:05"/>
+	<meta name="categories" content='"Rock, Pop'/>
+</head>
+<body><p>Hello</p></body>`,
+			wantErr:    true,
+			wantFields: []string{"categories"},
+		},
+		{
+			name:   "Warnings rejected in strict mode",
+			strict: true,
+			template: `<head>
+	<meta name="title" content="My First Blog Post"/>
+	<meta name="author" content="John Doe"/>
+	<meta name="post-date" content="2020-04-15 12:09:57"/>
+	<meta name="edit-date" content="2020-04-15 12:19:05"/>
+</head>
+<body></body>`,
+			wantErr:    true,
+			wantFields: []string{"body"},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, diags, err := NewValidator(tt.strict, nil).Validate("post.tpl", tt.template)
+			assert.Equal(t, tt.wantErr, err != nil)
+
+			fields := []string{}
+			for i := range diags {
+				fields = append(fields, diags[i].Field)
+			}
+			assert.ElementsMatch(t, tt.wantFields, fields)
+		})
+	}
+}
+
+func TestValidatePosition(t *testing.T) {
+	template := `<head>
+	<meta name="title" content="My First Blog Post"/>
+	<meta name="post-date" content="2020-04-15 12:09:57"/>
+	<meta name="edit-date" content="yesterday"/>
+	<meta name="author" content="John Doe"/>
+</head>
+<body><p>Hello</p></body>`
+
+	_, diags, err := NewValidator(false, nil).Validate("", template)
+	assert.Error(t, err)
+	assert.Len(t, diags, 1)
+	assert.Equal(t, 4, diags[0].Line)
+	assert.Equal(t, 2, diags[0].Column)
+}
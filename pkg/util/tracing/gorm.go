@@ -0,0 +1,94 @@
+package tracing
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// settings used to keep the context and span of gorm queries
+const (
+	contextSetting = "tracing:context"
+	spanSetting    = "tracing:span"
+)
+
+// InstrumentDB registers gorm callbacks that record a span for each query made by ds
+// with a context set by WithContext; queries with no context are not recorded. It must
+// be called once for each database.
+func InstrumentDB(ds *gorm.DB) {
+	// gorm logs each callback registered; a copy with no logger keeps it quiet
+	quiet := ds.New()
+	quiet.SetLogger(gorm.Logger{LogWriter: log.New(ioutil.Discard, "", 0)})
+	cb := quiet.Callback()
+
+	cb.Create().Before("gorm:create").Register("tracing:before_create", startQuery("INSERT"))
+	cb.Create().After("gorm:create").Register("tracing:after_create", endQuery)
+	cb.Query().Before("gorm:query").Register("tracing:before_query", startQuery("SELECT"))
+	cb.Query().After("gorm:query").Register("tracing:after_query", endQuery)
+	cb.RowQuery().Before("gorm:row_query").Register("tracing:before_row_query", startQuery("SELECT"))
+	cb.RowQuery().After("gorm:row_query").Register("tracing:after_row_query", endQuery)
+	cb.Update().Before("gorm:update").Register("tracing:before_update", startQuery("UPDATE"))
+	cb.Update().After("gorm:update").Register("tracing:after_update", endQuery)
+	cb.Delete().Before("gorm:delete").Register("tracing:before_delete", startQuery("DELETE"))
+	cb.Delete().After("gorm:delete").Register("tracing:after_delete", endQuery)
+}
+
+// WithContext returns a copy of ds whose queries are recorded as children of the span
+// in ctx
+func WithContext(ds *gorm.DB, ctx context.Context) *gorm.DB {
+	return ds.Set(contextSetting, ctx)
+}
+
+// DBContext returns the context set in ds by WithContext, or an empty context if there's
+// none; it's used to record queries that don't run gorm callbacks, like Exec
+func DBContext(ds *gorm.DB) context.Context {
+	if ctx, ok := ds.Get(contextSetting); ok {
+		return ctx.(context.Context)
+	}
+	return context.Background()
+}
+
+func startQuery(operation string) func(scope *gorm.Scope) {
+	return func(scope *gorm.Scope) {
+		ctx, ok := scope.Get(contextSetting)
+		if !ok {
+			return
+		}
+
+		// raw queries have no table
+		name, table := "sql "+operation, scope.TableName()
+		if table != "" {
+			name += " " + table
+		}
+
+		_, span := Start(ctx.(context.Context), name,
+			semconv.DBSystemKey.String(scope.Dialect().GetName()),
+			semconv.DBOperationKey.String(operation),
+			semconv.DBSQLTableKey.String(table))
+
+		scope.InstanceSet(spanSetting, span)
+	}
+}
+
+func endQuery(scope *gorm.Scope) {
+	v, ok := scope.InstanceGet(spanSetting)
+	if !ok {
+		return
+	}
+	span := v.(trace.Span)
+
+	// statements have placeholders, so values are not recorded
+	span.SetAttributes(semconv.DBStatementKey.String(strings.TrimSpace(scope.SQL)))
+
+	// records not found are expected by queries like FirstOrCreate
+	err := scope.DB().Error
+	if gorm.IsRecordNotFoundError(err) {
+		err = nil
+	}
+	End(span, err)
+}
@@ -0,0 +1,91 @@
+package tracing_test
+
+import (
+	"context"
+	"go-blog/pkg/util/tracing"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type note struct {
+	ID   int
+	Text string
+}
+
+func TestInstrumentDB(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	ds, err := gorm.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	defer ds.Close()
+	require.NoError(t, ds.AutoMigrate(&note{}).Error)
+
+	tracing.InstrumentDB(ds)
+
+	cases := []struct {
+		name      string
+		traced    bool
+		query     func(ds *gorm.DB) error
+		wantNames []string
+	}{
+		{
+			name:   "Create and query",
+			traced: true,
+			query: func(ds *gorm.DB) error {
+				if err := ds.Create(&note{Text: "first"}).Error; err != nil {
+					return err
+				}
+				return ds.Find(&[]note{}).Error
+			},
+			wantNames: []string{"sql INSERT notes", "sql SELECT notes"},
+		},
+		{
+			name:   "Record not found",
+			traced: true,
+			query: func(ds *gorm.DB) error {
+				ds.Where("text = ?", "none").First(&note{})
+				return nil
+			},
+			wantNames: []string{"sql SELECT notes"},
+		},
+		{
+			name:      "No context",
+			query:     func(ds *gorm.DB) error { return ds.Find(&[]note{}).Error },
+			wantNames: nil,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(recorder.Ended())
+
+			ctx, parent := tracing.Start(context.Background(), "parent")
+			db := ds
+			if tt.traced {
+				db = tracing.WithContext(ds, ctx)
+			}
+			require.NoError(t, tt.query(db))
+			parent.End()
+
+			// the parent span is the last one ended
+			spans := recorder.Ended()[before:]
+			spans = spans[:len(spans)-1]
+
+			var names []string
+			for _, span := range spans {
+				names = append(names, span.Name())
+				assert.Equal(t, parent.SpanContext().SpanID(), span.Parent().SpanID())
+				assert.NotEqual(t, "Error", span.Status().Code.String())
+			}
+			assert.Equal(t, tt.wantNames, names)
+		})
+	}
+}
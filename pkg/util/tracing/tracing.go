@@ -0,0 +1,161 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+
+	"github.com/labstack/gommon/random"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Supported exporters
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+// name of the tracer of the service spans
+const tracerName = "go-blog"
+
+// RequestIDKey is the attribute holding the ID of the request that started a span
+const RequestIDKey = attribute.Key("request.id")
+
+// IngestionIDKey is the attribute holding the ID of the ingestion of a template
+const IngestionIDKey = attribute.Key("ingestion.id")
+
+// Config represents the tracing settings
+type Config struct {
+	ServiceName    string
+	ServiceVersion string
+	Exporter       string  // none, stdout or otlp
+	Endpoint       string  // URL of the OTLP/HTTP collector, like http://localhost:4318
+	SampleRatio    float64 // ratio of traces recorded, from 0 to 1
+}
+
+// Setup sets the global tracer provider, exporting spans as configured, and the W3C
+// propagators; if the exporter is none or empty, spans are not recorded. The returned
+// function flushes pending spans, and must be called before exiting.
+func Setup(cfg Config) (shutdown func(ctx context.Context) error, err error) {
+
+	shutdown = func(ctx context.Context) error { return nil }
+
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	switch cfg.Exporter {
+	case "", ExporterNone:
+		return
+
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+
+	case ExporterOTLP:
+		var opts []otlptracehttp.Option
+		if opts, err = otlpOptions(cfg.Endpoint); err == nil {
+			exporter, err = otlptracehttp.New(context.Background(), opts...)
+		}
+
+	default:
+		err = fmt.Errorf("unsupported tracing exporter '%s'", cfg.Exporter)
+	}
+	if err != nil {
+		return
+	}
+
+	res := resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(cfg.ServiceName),
+		semconv.ServiceVersion(cfg.ServiceVersion))
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))))
+
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// returns the options to export spans to the collector at endpoint; TLS is used unless
+// the URL scheme is http
+func otlpOptions(endpoint string) (opts []otlptracehttp.Option, err error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid OTLP endpoint '%s'; a URL like http://localhost:4318 is expected", endpoint)
+	}
+
+	opts = append(opts, otlptracehttp.WithEndpoint(u.Host))
+	if u.Scheme == "http" {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	if u.Path != "" && u.Path != "/" {
+		opts = append(opts, otlptracehttp.WithURLPath(u.Path))
+	}
+	return
+}
+
+// Start starts a span as a child of the span in ctx, if any; the IDs of the request and
+// the template ingestion that started the trace are added to the span attributes
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if id := RequestID(ctx); id != "" {
+		attrs = append(attrs, RequestIDKey.String(id))
+	}
+	if id := IngestionID(ctx); id != "" {
+		attrs = append(attrs, IngestionIDKey.String(id))
+	}
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End ends the span, recording err as the span error if it's set
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx holding the ID of the request being served
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the ID of the request being served, or an empty string if ctx is
+// not bound to a request
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+type ingestionIDKey struct{}
+
+// WithIngestionID returns a copy of ctx holding the ID of the template being ingested
+func WithIngestionID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ingestionIDKey{}, id)
+}
+
+// IngestionID returns the ID of the template being ingested, or an empty string if ctx is
+// not bound to an ingestion
+func IngestionID(ctx context.Context) string {
+	id, _ := ctx.Value(ingestionIDKey{}).(string)
+	return id
+}
+
+// NewID returns a random ID, like the ones the RequestID middleware sets for requests
+func NewID() string {
+	return random.String(32)
+}
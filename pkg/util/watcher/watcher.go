@@ -1,21 +1,30 @@
 package watcher
 
 import (
+	"context"
 	"go-blog/pkg/util/log"
+	"go-blog/pkg/util/metrics"
+	"go-blog/pkg/util/tracing"
 	"os"
 	"path"
 	"path/filepath"
+	"sync"
 	"time"
 )
 
-// NewWatcher creates a new watcher instance
-func NewWatcher(path, templateExtension string, checkCycleDuration time.Duration, logger *log.Log, fileHandler func(string)) *Watcher {
+// NewWatcher creates a new watcher instance; each file found is sent to fileHandler with
+// an ID for its ingestion, used to tell its log messages apart. Files are not handled while
+// paused returns true, if it's set.
+func NewWatcher(path, templateExtension string, checkCycleDuration time.Duration, logger *log.Log, fileHandler func(ingestionID, filePath string), paused func() bool) *Watcher {
 	return &Watcher{
 		logger:             logger,
 		templatesExtension: templateExtension,
 		pathToWatch:        path,
 		checkCycleDuration: checkCycleDuration,
 		fileHandler:        fileHandler,
+		paused:             paused,
+		wake:               make(chan struct{}, 1),
+		handling:           make(map[string]bool),
 	}
 }
 
@@ -23,31 +32,78 @@ func NewWatcher(path, templateExtension string, checkCycleDuration time.Duration
 type Watcher struct {
 	logger             *log.Log
 	templatesExtension string
+	fileHandler        func(ingestionID, filePath string)
+	paused             func() bool
+
+	// folder and check cycle, which may be changed while watching; wake makes the
+	// watcher check again right away
+	mu                 sync.Mutex
 	pathToWatch        string
-	quitChannel        chan bool
 	checkCycleDuration time.Duration
-	fileHandler        func(string)
-}
+	wake               chan struct{}
 
-// Start begins with the watching process
-func (w *Watcher) Start() {
+	// state of the watching goroutine, used to report if it's alive
+	watching  bool
+	lastCheck time.Time
 
-	w.logger.Info("starting watcher on "+w.pathToWatch, nil)
+	// files sent to fileHandler that are still being handled; they're not sent again
+	// until it returns
+	handling map[string]bool
+}
 
-	go w.watch()
+// Start watches the folder until ctx is done; once it returns, no more files are sent
+// to be handled, though the ones already sent may still be being handled
+func (w *Watcher) Start(ctx context.Context) {
 
-	// keep running until it's stopped
-	w.quitChannel = make(chan bool)
-	<-w.quitChannel
+	pathToWatch, _ := w.settings()
+	w.logger.Info("starting watcher on "+pathToWatch, nil)
 
-	w.logger.Info("stopping watcher on "+w.pathToWatch, nil)
+	w.watch(ctx)
+
+	pathToWatch, _ = w.settings()
+	w.logger.Info("stopping watcher on "+pathToWatch, nil)
 }
 
-// Stop ends the watching process
-func (w *Watcher) Stop() {
-	if w.quitChannel != nil {
-		w.quitChannel <- true
+// Reconfigure changes the folder watched and the time between checks; the folder is
+// checked again right away
+func (w *Watcher) Reconfigure(pathToWatch string, checkCycleDuration time.Duration) {
+	w.mu.Lock()
+	if pathToWatch != w.pathToWatch {
+		w.logger.Info("watched folder changed", map[string]interface{}{"path": pathToWatch, "previous": w.pathToWatch})
 	}
+	w.pathToWatch = pathToWatch
+	w.checkCycleDuration = checkCycleDuration
+	w.mu.Unlock()
+
+	select {
+	case w.wake <- struct{}{}:
+	default: // a check is already pending
+	}
+}
+
+// returns the folder watched and the time between checks
+func (w *Watcher) settings() (string, time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.pathToWatch, w.checkCycleDuration
+}
+
+// Alive returns true if the folder is being watched, and it was checked for new files
+// within the last two check cycles
+func (w *Watcher) Alive() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.watching && time.Since(w.lastCheck) <= 2*w.checkCycleDuration+time.Second
+}
+
+// records a check for new files; the watcher stops being alive once watching is false
+func (w *Watcher) setState(watching bool) {
+	w.mu.Lock()
+	w.watching = watching
+	w.lastCheck = time.Now()
+	w.mu.Unlock()
 }
 
 // get files in pathToLook, filter by the indicated file extension
@@ -62,23 +118,49 @@ func (w *Watcher) listExsitingFiles(pathToLook string, extension string) (curren
 	return
 }
 
-func (w *Watcher) watch() {
+func (w *Watcher) watch(ctx context.Context) {
+
+	defer w.setState(false)
 
 	// process existing files
+	wasPaused := false
 	for {
+		w.setState(true)
+		pathToWatch, _ := w.settings()
+
+		// paused watchers are still alive, but files are left for later
+		isPaused := w.paused != nil && w.paused()
+		if isPaused != wasPaused {
+			if isPaused {
+				w.logger.Info("watcher paused; templates are left for later", map[string]interface{}{"path": pathToWatch})
+			} else {
+				w.logger.Info("watcher resumed", map[string]interface{}{"path": pathToWatch})
+			}
+			wasPaused = isPaused
+		}
+		if isPaused {
+			if !w.sleep(ctx) {
+				return
+			}
+			continue
+		}
+
 		w.logger.Debug("checking for new files", nil)
-		existingFiles, err := w.listExsitingFiles(w.pathToWatch, w.templatesExtension)
+		cycleStart := time.Now()
+		existingFiles, err := w.listExsitingFiles(pathToWatch, w.templatesExtension)
 		if err != nil {
-			w.logger.Error("error reading existing files in folder to watch", err, map[string]interface{}{"path": w.pathToWatch})
+			w.logger.Error("error reading existing files in folder to watch", err, map[string]interface{}{"path": pathToWatch})
 			return
 		}
 
 		processedCount := 0
 		if len(existingFiles) > 0 {
 			for i := range existingFiles {
-				if path.Dir(existingFiles[i]) == w.pathToWatch {
-					w.logger.Info("found existing template; sending to be processed", map[string]interface{}{"file": existingFiles[i]})
-					go w.fileHandler(existingFiles[i])
+				// files left are found again on the next start
+				if ctx.Err() != nil {
+					return
+				}
+				if path.Dir(existingFiles[i]) == pathToWatch && w.dispatch(existingFiles[i]) {
 					processedCount++
 				}
 			}
@@ -88,8 +170,58 @@ func (w *Watcher) watch() {
 		// 	w.logger.Debug("no new files found", nil)
 		// }
 
+		metrics.WatcherCycleDuration.Observe(time.Since(cycleStart).Seconds())
+
 		// wait before checking again
-		time.Sleep(w.checkCycleDuration)
+		if !w.sleep(ctx) {
+			return
+		}
 	}
 
 }
+
+// sends the file to be handled, unless it's still being handled since a previous check;
+// returns false if it was not sent
+func (w *Watcher) dispatch(filePath string) bool {
+	w.mu.Lock()
+	if w.handling[filePath] {
+		w.mu.Unlock()
+		return false
+	}
+	w.handling[filePath] = true
+	metrics.WatcherQueueDepth.Set(float64(len(w.handling)))
+	w.mu.Unlock()
+
+	ingestionID := tracing.NewID()
+	w.logger.Info("found existing template; sending to be processed", map[string]interface{}{"file": filePath, "ingestion_id": ingestionID})
+
+	go func() {
+		defer func() {
+			w.mu.Lock()
+			delete(w.handling, filePath)
+			metrics.WatcherQueueDepth.Set(float64(len(w.handling)))
+			w.mu.Unlock()
+		}()
+
+		w.fileHandler(ingestionID, filePath)
+	}()
+
+	return true
+}
+
+// waits for a check cycle, or until the watcher is reconfigured; returns false if ctx
+// is done first
+func (w *Watcher) sleep(ctx context.Context) bool {
+	_, checkCycleDuration := w.settings()
+	timer := time.NewTimer(checkCycleDuration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-w.wake:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
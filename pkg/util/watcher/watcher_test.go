@@ -0,0 +1,58 @@
+package watcher_test
+
+import (
+	"context"
+	"go-blog/pkg/util/log"
+	"go-blog/pkg/util/metrics"
+	"go-blog/pkg/util/watcher"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatcherInFlight(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watcher")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "post.tpl")
+	assert.NoError(t, ioutil.WriteFile(file, []byte("<head></head>"), 0644))
+
+	// the file is handled until release is closed
+	var mu sync.Mutex
+	calls := 0
+	release := make(chan struct{})
+	handler := func(ingestionID, filePath string) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		<-release
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := watcher.NewWatcher(dir, ".tpl", 5*time.Millisecond, log.New(), handler, nil)
+	done := make(chan struct{})
+	go func() {
+		w.Start(ctx)
+		close(done)
+	}()
+
+	// files still being handled are not sent again
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	assert.Equal(t, 1, calls)
+	mu.Unlock()
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.WatcherQueueDepth))
+
+	cancel()
+	<-done
+	close(release)
+
+	assert.Eventually(t, func() bool { return testutil.ToFloat64(metrics.WatcherQueueDepth) == 0 }, time.Second, 5*time.Millisecond)
+}